@@ -3,7 +3,7 @@ package qml
 // #cgo CPPFLAGS: -I./cpp
 // #cgo CXXFLAGS: -std=c++0x -pedantic-errors -Wall -fno-strict-aliasing
 // #cgo LDFLAGS: -lstdc++
-// #cgo pkg-config: Qt5Core Qt5Widgets Qt5Quick
+// #cgo pkg-config: Qt5Core Qt5Widgets Qt5Quick Qt5Network
 //
 // #include <stdlib.h>
 //
@@ -12,10 +12,13 @@ package qml
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 
@@ -46,6 +49,137 @@ func init() {
 // The Run function must necessarily be called from the same goroutine as
 // the main function or the application may fail when running on Mac OS.
 func Run(f func() error) error {
+	return RunContext(context.Background(), f)
+}
+
+// RunOffscreen is like Run, but uses Qt's offscreen platform plugin so
+// that no display server is necessary, as is often the case when running
+// tests in continuous integration environments. Windows are never shown
+// on any screen, yet they are rendered and Window.Grab and Window.Snapshot
+// still produce images of their content.
+//
+// Unless the QT_QUICK_BACKEND environment variable is already set, the
+// software scene graph backend (Qt 5.8+) is used, as OpenGL is commonly
+// unavailable without a display. Effects and items that depend on OpenGL,
+// such as ShaderEffect and painting via GL functions, do not work with it.
+// Set QT_QUICK_BACKEND to another backend, such as "opengl", to attempt
+// hardware rendering instead.
+//
+// There is no real user input in offscreen mode, so interactions must be
+// simulated by calling methods or emitting signals from Go.
+func RunOffscreen(f func() error) error {
+	os.Setenv("QT_QPA_PLATFORM", "offscreen")
+	if os.Getenv("QT_QUICK_BACKEND") == "" {
+		os.Setenv("QT_QUICK_BACKEND", "software")
+	}
+	return Run(f)
+}
+
+// SetApplication sets the name, organization, and version of the
+// application. QML code sees them as Qt.application.name,
+// Qt.application.organization, and Qt.application.version, and Qt uses
+// them in places such as the default window title and the location of
+// settings stored by QML's Settings type.
+//
+// SetApplication must be called before Run, and panics otherwise.
+func SetApplication(name, org, version string) {
+	if atomic.LoadInt32(&initialized) != 0 {
+		panic("qml.SetApplication must be called before qml.Run")
+	}
+	cname, corg, cversion := C.CString(name), C.CString(org), C.CString(version)
+	C.setApplicationInfo(cname, corg, cversion)
+	C.free(unsafe.Pointer(cname))
+	C.free(unsafe.Pointer(corg))
+	C.free(unsafe.Pointer(cversion))
+}
+
+// QtVersion returns the version of the Qt libraries in use at runtime,
+// such as "5.15.2". It may be called before Run.
+func QtVersion() string {
+	return C.GoString(C.qtRuntimeVersion())
+}
+
+// EnableHighDpiScaling sets whether the user interface is scaled
+// according to the pixel density of each screen (Qt 5.6+), so that
+// it has the same physical size on high-DPI screens as on others.
+//
+// EnableHighDpiScaling must be called before Run, as Qt only considers
+// the setting when the application is created. It does nothing if
+// called afterwards.
+func EnableHighDpiScaling(enabled bool) {
+	if atomic.LoadInt32(&initialized) == 0 {
+		C.setHighDpiScaling(cbool(enabled))
+	}
+}
+
+// ScaleFactorRoundingPolicy defines how fractional scale factors, as
+// found on screens set up with 150% scaling, are handled by high-DPI
+// scaling. See SetHighDpiScaleFactorRoundingPolicy.
+type ScaleFactorRoundingPolicy int
+
+const (
+	RoundScaleFactor            ScaleFactorRoundingPolicy = iota // Round to the nearest integer
+	CeilScaleFactor                                              // Round up
+	FloorScaleFactor                                             // Round down
+	RoundPreferFloorScaleFactor                                  // Round to nearest, with .5 rounded down
+	PassThroughScaleFactor                                       // Use fractional factors as is
+)
+
+// SetHighDpiScaleFactorRoundingPolicy sets how fractional scale factors
+// are handled by high-DPI scaling (Qt 5.14+). Rounding keeps the user
+// interface crisp and aligned to pixels, at the cost of an imprecise size,
+// while PassThroughScaleFactor keeps the precise size, at the cost of
+// potentially blurry or misaligned rendering.
+//
+// SetHighDpiScaleFactorRoundingPolicy must be called before Run, as Qt
+// only considers the policy when the application is created. It does
+// nothing if called afterwards, or with Qt versions before 5.14.
+func SetHighDpiScaleFactorRoundingPolicy(policy ScaleFactorRoundingPolicy) {
+	if atomic.LoadInt32(&initialized) == 0 {
+		C.setHighDpiScaleFactorRoundingPolicy(C.int(policy))
+	}
+}
+
+// Backend identifies the scene graph backend used to render QML windows.
+type Backend int
+
+const (
+	AutoBackend     Backend = iota // Chosen by Qt, honoring QT_QUICK_BACKEND
+	OpenGLBackend                  // Hardware accelerated rendering via OpenGL
+	SoftwareBackend                // Rendering on the CPU, without OpenGL
+)
+
+// SetGraphicsBackend sets the scene graph backend used to render QML
+// windows. It must be called before Run, and returns an error otherwise.
+// Choosing a backend other than AutoBackend requires Qt 5.8 or later.
+//
+// The software backend works reliably where OpenGL is unavailable or
+// unstable, such as in virtual machines, remote desktop sessions, and
+// headless servers, at the cost of performance and of features that
+// depend on OpenGL, such as ShaderEffect.
+func SetGraphicsBackend(backend Backend) error {
+	if atomic.LoadInt32(&initialized) != 0 {
+		return fmt.Errorf("graphics backend must be set before qml.Run is called")
+	}
+	if backend < AutoBackend || backend > SoftwareBackend {
+		return fmt.Errorf("unknown graphics backend: %d", backend)
+	}
+	if cerr := C.setSceneGraphBackend(C.int(backend)); cerr != nil {
+		return cerror(cerr)
+	}
+	return nil
+}
+
+// RunContext is like Run, but the event loop is also terminated when ctx
+// is done, as may happen when the application is asked to shut down.
+// In that case f is expected to return soon after observing ctx.Done,
+// and RunContext waits for it so that deferred cleanup may run. Until f
+// returns, functions it sends to RunMain are still executed in the main
+// thread, even though no further events are processed.
+//
+// Pending GUI events are flushed before RunContext returns the error
+// returned by f.
+func RunContext(ctx context.Context, f func() error) error {
 	if cdata.Ref() != guiMainRef {
 		panic("Run must be called on the initial goroutine so apps are portable to Mac OS")
 	}
@@ -60,14 +194,50 @@ func Run(f func() error) error {
 		done <- f()
 		C.applicationExit()
 	}()
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+			return
+		}
+		// As done by RunMain, but giving up once the loop below is gone,
+		// as may happen when f returns while ctx is being canceled.
+		if atomic.AddInt32(&guiIdleRun, 1) == 1 {
+			C.idleTimerStart()
+		}
+		select {
+		case guiFunc <- func() { C.applicationExit() }:
+			<-guiDone
+		case <-stop:
+			atomic.AddInt32(&guiIdleRun, -1)
+		}
+	}()
 	C.applicationExec()
-	return <-done
+	for {
+		select {
+		case err := <-done:
+			close(stop)
+			C.applicationFlushAll()
+			return err
+		case f := <-guiFunc:
+			// The event loop was terminated via ctx while f is still running.
+			f()
+			guiDone <- struct{}{}
+			atomic.AddInt32(&guiIdleRun, -1)
+		}
+	}
 }
 
 // RunMain runs f in the main QML thread and waits for f to return.
 //
-// This is meant to be used by extensions that integrate directly with the
-// underlying QML logic.
+// Functions and methods of the qml package already take care of
+// running in the main thread when necessary, so RunMain is mostly
+// useful for grouping several operations so they run without other
+// QML activity in between, and for extensions that integrate directly
+// with the underlying QML logic. RunMain may be called from any
+// goroutine, including from within the main thread itself, in which
+// case f is run right away.
 func RunMain(f func()) {
 	ref := cdata.Ref()
 	if ref == guiMainRef || ref == atomic.LoadUintptr(&guiPaintRef) {
@@ -88,6 +258,44 @@ func RunMain(f func()) {
 	<-guiDone
 }
 
+// Go schedules f to run in the main QML thread and returns without
+// waiting for it to run. Functions scheduled via Go run in the order
+// they were scheduled, even when called from the main thread itself.
+func Go(f func()) {
+	goQueue.Lock()
+	goQueue.funcs = append(goQueue.funcs, f)
+	if !goQueue.running {
+		goQueue.running = true
+		go runGoQueue()
+	}
+	goQueue.Unlock()
+}
+
+var goQueue struct {
+	sync.Mutex
+	funcs   []func()
+	running bool
+}
+
+func runGoQueue() {
+	for {
+		goQueue.Lock()
+		funcs := goQueue.funcs
+		goQueue.funcs = nil
+		if len(funcs) == 0 {
+			goQueue.running = false
+			goQueue.Unlock()
+			return
+		}
+		goQueue.Unlock()
+		RunMain(func() {
+			for _, f := range funcs {
+				f()
+			}
+		})
+	}
+}
+
 // Lock freezes all QML activity by blocking the main event loop.
 // Locking is necessary before updating shared data structures
 // without race conditions.
@@ -117,7 +325,15 @@ func Unlock() {
 	})
 }
 
-// Flush synchronously flushes all pending QML activities.
+// Flush synchronously flushes all pending QML activities, by processing
+// the events that are pending in the main event loop, such as queued
+// signal emissions, expired timers, and deferred object deletions.
+//
+// This is useful in tests, to observe the effects of a change without
+// sleeping for an arbitrary amount of time. Flush may be called from any
+// goroutine, as the processing always happens in the main thread, and it
+// does not wait for events that are posted while processing, so it never
+// blocks indefinitely.
 func Flush() {
 	// TODO Better testing for this.
 	RunMain(func() {
@@ -289,10 +505,10 @@ func addrOf(gvalue interface{}) uintptr {
 var typeNew = make(map[*valueFold]bool)
 
 //export hookGoValueTypeNew
-func hookGoValueTypeNew(cvalue unsafe.Pointer, specp unsafe.Pointer) (foldp unsafe.Pointer) {
+func hookGoValueTypeNew(cvalue unsafe.Pointer, specIndex C.intptr_t) (foldp unsafe.Pointer) {
 	// Initialization is postponed until the engine is available, so that
 	// we can hand Init the qml.Object that represents the object.
-	init := reflect.ValueOf((*TypeSpec)(specp).Init)
+	init := reflect.ValueOf(types[specIndex].Init)
 	fold := &valueFold{
 		init:   init,
 		gvalue: reflect.New(init.Type().In(0).Elem()).Interface(),
@@ -305,6 +521,20 @@ func hookGoValueTypeNew(cvalue unsafe.Pointer, specp unsafe.Pointer) (foldp unsa
 	return unsafe.Pointer(fold)
 }
 
+//export hookSingletonFactory
+func hookSingletonFactory(enginep unsafe.Pointer, specIndex C.intptr_t) (cvalue unsafe.Pointer) {
+	engine := engines[enginep]
+	if engine == nil {
+		panic("singleton requested by an unknown engine")
+	}
+	gvalue := types[specIndex].factory(engine)
+	if gvalue == nil {
+		panic("singleton factory returned nil")
+	}
+	// The engine owns the singleton, so it is destroyed with it.
+	return wrapGoValue(engine, gvalue, cppOwner)
+}
+
 //export hookGoValueDestroyed
 func hookGoValueDestroyed(enginep unsafe.Pointer, foldp unsafe.Pointer) {
 	fold := (*valueFold)(foldp)
@@ -380,8 +610,12 @@ func hookGoValueReadField(enginep, foldp unsafe.Pointer, reflectIndex, getIndex,
 		return
 	}
 
+	// Slices are handed over as lists, and colors, times, geometry values,
+	// and registered value types as native values. Other structs, and slices
+	// with methods, are handed over by reference so their fields may be
+	// changed and their methods called.
 	fieldk := field.Kind()
-	if fieldk == reflect.Slice || fieldk == reflect.Struct && field.Type() != typeRGBA {
+	if fieldk == reflect.Slice && !nativeContainer(field.Type()) || fieldk == reflect.Struct && !nativeStruct(field.Type()) {
 		if field.CanAddr() {
 			field = field.Addr()
 		} else if !hashable(field.Interface()) {
@@ -425,6 +659,10 @@ func hookGoValueWriteField(enginep, foldp unsafe.Pointer, reflectIndex, setIndex
 
 	assign := unpackDataValue(assigndv, fold.engine)
 
+	// The written field is notified by the C++ side itself.
+	before := notifyState(fold.gvalue)
+	defer notifyChanged(fold.gvalue, before, int(reflectIndex))
+
 	// TODO Return false to the call site if it fails. That's how Qt seems to handle it internally.
 	err := convertAndSet(field, reflect.ValueOf(assign), setMethod)
 	if err != nil {
@@ -432,6 +670,77 @@ func hookGoValueWriteField(enginep, foldp unsafe.Pointer, reflectIndex, setIndex
 	}
 }
 
+var notifyFieldsCache = make(map[reflect.Type][]int)
+
+// notifyFields returns the indexes of the fields of the struct type t
+// tagged with the notify option, as in `qml:"name,notify"`.
+func notifyFields(t reflect.Type) []int {
+	indexes, ok := notifyFieldsCache[t]
+	if ok {
+		return indexes
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := fieldName(field); !ok {
+			continue
+		}
+		for _, option := range strings.Split(field.Tag.Get("qml"), ",")[1:] {
+			if option == "notify" {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	notifyFieldsCache[t] = indexes
+	return indexes
+}
+
+// notifyState returns the values of the fields of gvalue tagged with the
+// notify option, to be compared by notifyChanged once Go logic invoked by
+// QML has run. It returns nil if gvalue is not a pointer to a struct with
+// such fields.
+func notifyState(gvalue interface{}) []interface{} {
+	v := reflect.ValueOf(gvalue)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	indexes := notifyFields(v.Type())
+	if len(indexes) == 0 {
+		return nil
+	}
+	state := make([]interface{}, len(indexes))
+	for i, index := range indexes {
+		state[i] = v.Field(index).Interface()
+	}
+	return state
+}
+
+// notifyChanged emits the change signals of the fields of gvalue tagged
+// with the notify option whose values differ from those in before, except
+// for the field with index skip.
+func notifyChanged(gvalue interface{}, before []interface{}, skip int) {
+	if before == nil {
+		return
+	}
+	v := reflect.ValueOf(gvalue).Elem()
+	for i, index := range notifyFields(v.Type()) {
+		field := v.Field(index)
+		if index != skip && !reflect.DeepEqual(before[i], field.Interface()) {
+			Changed(gvalue, field.Addr().Interface())
+		}
+	}
+}
+
+// nativeStruct returns whether values of the struct type t are handed
+// over to QML as native values rather than by reference.
+func nativeStruct(t reflect.Type) bool {
+	switch t {
+	case typeRGBA, typeTime, typePoint, typeSize, typeRect:
+		return true
+	}
+	return valueTypes[t] != nil
+}
+
 func convertAndSet(to, from reflect.Value, setMethod reflect.Value) (err error) {
 	var toType reflect.Type
 	if setMethod.IsValid() {
@@ -452,7 +761,7 @@ func convertAndSet(to, from reflect.Value, setMethod reflect.Value) (err error)
 		from = reflect.MakeSlice(toType, len(list.data), len(list.data))
 		elemType := toType.Elem()
 		for i, elem := range list.data {
-			from.Index(i).Set(reflect.ValueOf(elem).Convert(elemType))
+			from.Index(i).Set(convertElem(elem, elemType))
 		}
 	} else if fromType == typeMap && toType.Kind() == reflect.Map {
 		qmap := from.Interface().(*Map)
@@ -460,12 +769,10 @@ func convertAndSet(to, from reflect.Value, setMethod reflect.Value) (err error)
 		elemType := toType.Elem()
 		for i := 0; i < len(qmap.data); i += 2 {
 			key := reflect.ValueOf(qmap.data[i])
-			val := reflect.ValueOf(qmap.data[i+1])
-			if val.Type() != elemType {
-				val = val.Convert(elemType)
-			}
-			from.SetMapIndex(key, val)
+			from.SetMapIndex(key, convertElem(qmap.data[i+1], elemType))
 		}
+	} else if fromType == typeMap && valueTypes[toType] != nil {
+		from = valueTypes[toType].fromMap(from.Interface().(*Map))
 	} else if toType != fromType {
 		from = from.Convert(toType)
 	}
@@ -477,6 +784,27 @@ func convertAndSet(to, from reflect.Value, setMethod reflect.Value) (err error)
 	return nil
 }
 
+// convertElem converts elem, an element of a list or map obtained from
+// QML, into a value of elemType. Nested lists and maps are converted
+// recursively, into []interface{} and map[string]interface{} values when
+// elemType is an empty interface. It panics if the conversion fails.
+func convertElem(elem interface{}, elemType reflect.Type) reflect.Value {
+	if elemType == typeIface {
+		elem = nativeValue(elem)
+	}
+	if elem == nil {
+		return reflect.Zero(elemType)
+	}
+	if elemType == typeIface {
+		return reflect.ValueOf(&elem).Elem()
+	}
+	to := reflect.New(elemType).Elem()
+	if err := convertAndSet(to, reflect.ValueOf(elem), reflect.Value{}); err != nil {
+		panic(err)
+	}
+	return to
+}
+
 var (
 	dataValueSize  = uintptr(unsafe.Sizeof(C.DataValue{}))
 	dataValueArray [C.MaxParams]C.DataValue
@@ -512,7 +840,9 @@ func hookGoValueCallMethod(enginep, foldp unsafe.Pointer, reflectIndex C.int, ar
 		params[i] = param
 	}
 
+	before := notifyState(fold.gvalue)
 	result := method.Call(params[:numIn])
+	notifyChanged(fold.gvalue, before, -1)
 
 	if len(result) == 1 {
 		packDataValue(result[0].Interface(), args, fold.engine, jsOwner)
@@ -548,7 +878,7 @@ func printPaintPanic() {
 }
 
 //export hookGoValuePaint
-func hookGoValuePaint(enginep, foldp unsafe.Pointer, reflectIndex C.intptr_t) {
+func hookGoValuePaint(enginep, foldp unsafe.Pointer, reflectIndex C.intptr_t, painterp unsafe.Pointer) {
 	// Besides a convenience this is a workaround for http://golang.org/issue/8588
 	defer printPaintPanic()
 	defer atomic.StoreUintptr(&guiPaintRef, 0)
@@ -562,7 +892,7 @@ func hookGoValuePaint(enginep, foldp unsafe.Pointer, reflectIndex C.intptr_t) {
 		return
 	}
 
-	painter := &Painter{engine: fold.engine, obj: &Common{fold.cvalue, fold.engine}}
+	painter := &Painter{engine: fold.engine, obj: CommonOf(fold.cvalue, fold.engine), addr: painterp}
 	v := reflect.ValueOf(fold.gvalue)
 	method := v.Method(int(reflectIndex))
 	method.Call([]reflect.Value{reflect.ValueOf(painter)})
@@ -617,7 +947,7 @@ func _initGoType(fold *valueFold, schedulePaint bool) {
 		return
 	}
 	// TODO Would be good to preserve identity on the Go side. See unpackDataValue as well.
-	obj := &Common{engine: fold.engine, addr: fold.cvalue}
+	obj := CommonOf(fold.cvalue, fold.engine)
 	fold.init.Call([]reflect.Value{reflect.ValueOf(fold.gvalue), reflect.ValueOf(obj)})
 	fold.init = reflect.Value{}
 	if schedulePaint {
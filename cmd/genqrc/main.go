@@ -16,6 +16,13 @@
 // * a filename. The file will be imported directly
 // * a directory. all files within the directory will be imported
 //
+// A path of "-" reads further paths from the standard input, one per line, and
+// a path of "@file" reads them from the named response file. Each line is
+// handled exactly like a path provided in the command line. Blank lines and
+// lines starting with "#" are ignored. For example:
+//
+//     find ui -name '*.qml' | genqrc - @assets.txt
+//
 // For example, the following will load a .qml file from the resource pack, and
 // that file may in turn reference other content (code, images, etc) in the pack:
 //
@@ -29,6 +36,12 @@
 //
 // Then, just run "go generate" to update the qrc.go file.
 //
+// The generated file may be written elsewhere with the -o flag. When it's
+// written to a different directory, the package name is derived from that
+// directory unless the -package flag is provided:
+//
+//     genqrc -o resources/resources_gen.go qml.qrc
+//
 // During development, the generated qrc.go can repack the filesystem content at
 // runtime to avoid the process of regenerating the qrc.go file and rebuilding the
 // application to test every minor change made. Runtime repacking is enabled by
@@ -40,20 +53,102 @@
 // the changes are performed, genqrc must be run again to update the content that
 // will ship with built binaries.
 //
+// Files and directories found while walking a directory may be skipped with
+// the -exclude flag, which may be repeated. Patterns are matched against the
+// slash-separated path relative to the walked directory using the syntax of
+// path.Match, with "**" matching any number of path elements. Patterns without
+// a slash also match the file name at any depth. For example:
+//
+//     genqrc -exclude '*.tmp' -exclude '.git/**' ui
+//
+// Path prefixes may be rewritten with the -remap flag, which may be repeated.
+// For example, the following exposes the file images/logo.png under the URL
+// "qrc:///assets/logo.png". Remapping two files to the same path is an error.
+//
+//     genqrc -remap images=assets images
+//
+// Resources may be split into named groups with the -group flag, which may be
+// repeated and takes a group name and a comma-separated list of paths. Each
+// group is packed separately into an exported variable named after the group,
+// which is not loaded automatically. Only the resources under the paths
+// provided as arguments are loaded when the program starts, so rarely used
+// resources may be loaded on demand instead. For example:
+//
+//     genqrc -group tutorial:tutorial,images/tutorial main.qml ui
+//
+// packs the tutorial resources into the ResourcesTutorial variable, which may
+// later be loaded with:
+//
+//     qml.LoadResources(ResourcesTutorial)
+//
+// The qrcResourcesHash, ResourcesDigest, and -manifest output cover the
+// resources loaded at startup only, while -check verifies all groups.
+//
+// Symbolic links found while walking a directory are skipped with a warning,
+// unless the -follow-symlinks flag is provided. In that case, linked files are
+// packed under the path of the link, and linked directories are walked as if
+// they were found at the path of the link. Links that would lead the walk into
+// one of its own parent directories are skipped with a warning.
+//
+// The -check flag verifies that a previously generated file is up to date,
+// without writing anything. All paths are processed as usual, and the command
+// fails if any resources are missing, if a .qrc file cannot be parsed, or if
+// the resources embedded in the existing file differ from the ones just packed.
+// This is useful for ensuring in continuous integration that the committed
+// file is current.
+//
+// The generated file also defines a qrcResourcesHash constant holding the
+// Hash of the packed resources, which may be compared at runtime with
+// qml.LoadedResourcesHash or with the hash of freshly packed resources.
+//
+// The exported ResourcesDigest constant holds the same hash in the form
+// "sha256:<hex>", which is stable while the inputs are unchanged, and
+// ResourcesBuiltAt holds the time the file was generated in RFC 3339 format.
+// The -no-timestamp flag leaves ResourcesBuiltAt empty so that the generated
+// file is reproducible.
+//
+// A listing of the packed files may be written with the -manifest flag. Each
+// line holds the uncompressed size of a file and its path, separated by a tab,
+// sorted by path. The last line holds the total size.
+//
+// Packed files may be compressed with the -compress flag, which takes a zlib
+// compression level from 1 to 9. Compressed files are decompressed by the QML
+// engine when first accessed, and files that would not shrink significantly,
+// such as PNG images, are stored as-is.
+//
+// Resources in a .qrc file with a lang attribute, as in <qresource lang="fr_FR">,
+// are packed under a "+lang" directory next to where they would otherwise be.
+// The file selector installed on every engine picks these variants when the lang
+// matches the locale set with Engine.SetUILanguage, or the system locale name.
+//
 // NOTES:
 // * Files labeled *.qrc are not parsed unless explicitely set in the parameters list.
-// * All *.pri and *.qmltypes files are ignored.
-// * qmldir files are currently ignored and so import definitions need to be handled accordingly.
+// * All *.pri files are ignored. *.qmltypes files are ignored unless -include-qmltypes is set.
+// * qmldir files are packed so modules may be imported from the pack. Files referenced by
+//   a qmldir that are not being packed are reported. Use -include-qmldir=false to skip them.
 
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
+	"time"
+	"unicode"
 	"encoding/xml"
 
 	"gopkg.in/qml.v1"
@@ -76,6 +171,13 @@ paths can be:
 * a filename. The file will be imported directly
 * a directory. all files within the directory will be imported
 
+A path of "-" reads further paths from the standard input, one per line, and
+a path of "@file" reads them from the named response file. Each line is
+handled exactly like a path provided in the command line. Blank lines and
+lines starting with "#" are ignored. For example:
+
+    find ui -name '*.qml' | genqrc - @assets.txt
+
 For example, the following will load a .qml file from the resource pack, and
 that file may in turn reference other content (code, images, etc) in the pack:
 
@@ -89,6 +191,12 @@ file in the project (assuming the subdirectories ./code/ and ./images/ exist):
 
 Then, just run "go generate" to update the qrc.go file.
 
+The generated file may be written elsewhere with the -o flag. When it's
+written to a different directory, the package name is derived from that
+directory unless the -package flag is provided:
+
+    genqrc -o resources/resources_gen.go qml.qrc
+
 During development, the generated qrc.go can repack the filesystem content at
 runtime to avoid the process of regenerating the qrc.go file and rebuilding the
 application to test every minor change made. Runtime repacking is enabled by
@@ -100,16 +208,114 @@ This does not update the static content in the qrc.go file, though, so after
 the changes are performed, genqrc must be run again to update the content that
 will ship with built binaries.
 
+Files and directories found while walking a directory may be skipped with
+the -exclude flag, which may be repeated. Patterns are matched against the
+slash-separated path relative to the walked directory using the syntax of
+path.Match, with "**" matching any number of path elements. Patterns without
+a slash also match the file name at any depth. For example:
+
+    genqrc -exclude '*.tmp' -exclude '.git/**' ui
+
+Path prefixes may be rewritten with the -remap flag, which may be repeated.
+For example, the following exposes the file images/logo.png under the URL
+"qrc:///assets/logo.png". Remapping two files to the same path is an error.
+
+    genqrc -remap images=assets images
+
+Resources may be split into named groups with the -group flag, which may be
+repeated and takes a group name and a comma-separated list of paths. Each
+group is packed separately into an exported variable named after the group,
+which is not loaded automatically. Only the resources under the paths
+provided as arguments are loaded when the program starts, so rarely used
+resources may be loaded on demand instead. For example:
+
+    genqrc -group tutorial:tutorial,images/tutorial main.qml ui
+
+packs the tutorial resources into the ResourcesTutorial variable, which may
+later be loaded with:
+
+    qml.LoadResources(ResourcesTutorial)
+
+The qrcResourcesHash, ResourcesDigest, and -manifest output cover the
+resources loaded at startup only, while -check verifies all groups.
+
+Symbolic links found while walking a directory are skipped with a warning,
+unless the -follow-symlinks flag is provided. In that case, linked files are
+packed under the path of the link, and linked directories are walked as if
+they were found at the path of the link. Links that would lead the walk into
+one of its own parent directories are skipped with a warning.
+
+The -check flag verifies that a previously generated file is up to date,
+without writing anything. All paths are processed as usual, and the command
+fails if any resources are missing, if a .qrc file cannot be parsed, or if
+the resources embedded in the existing file differ from the ones just packed.
+This is useful for ensuring in continuous integration that the committed
+file is current.
+
+The generated file also defines a qrcResourcesHash constant holding the
+Hash of the packed resources, which may be compared at runtime with
+qml.LoadedResourcesHash or with the hash of freshly packed resources.
+
+The exported ResourcesDigest constant holds the same hash in the form
+"sha256:<hex>", which is stable while the inputs are unchanged, and
+ResourcesBuiltAt holds the time the file was generated in RFC 3339 format.
+The -no-timestamp flag leaves ResourcesBuiltAt empty so that the generated
+file is reproducible.
+
+A listing of the packed files may be written with the -manifest flag. Each
+line holds the uncompressed size of a file and its path, separated by a tab,
+sorted by path. The last line holds the total size.
+
+Packed files may be compressed with the -compress flag, which takes a zlib
+compression level from 1 to 9. Compressed files are decompressed by the QML
+engine when first accessed, and files that would not shrink significantly,
+such as PNG images, are stored as-is.
+
+Resources in a .qrc file with a lang attribute, as in <qresource lang="fr_FR">,
+are packed under a "+lang" directory next to where they would otherwise be.
+The file selector installed on every engine picks these variants when the lang
+matches the locale set with Engine.SetUILanguage, or the system locale name.
+
 NOTES:
 * Files labeled *.qrc are not parsed unless explicitely set in the parameters list.
-* All *.pri and *.qmltypes files are ignored.
-* qmldir files are currently ignored and so import definitions need to be handled accordingly.
+* All *.pri files are ignored. *.qmltypes files are ignored unless -include-qmltypes is set.
+* qmldir files are packed so modules may be imported from the pack. Files referenced by
+  a qmldir that are not being packed are reported. Use -include-qmldir=false to skip them.
 `
 
 var packageName = flag.String("package", "main", "package name that qrc.go will be under (not needed for go generate)")
+var output = flag.String("o", "qrc.go", "`path` of the generated file; parent directories are created as needed")
+var manifest = flag.String("manifest", "", "write a listing of packed resources and their sizes to `file`")
+var check = flag.Bool("check", false, "verify that the generated file is up to date instead of writing it")
+var includeQmldir = flag.Bool("include-qmldir", true, "pack qmldir files so modules may be imported from the pack")
+var includeQmltypes = flag.Bool("include-qmltypes", false, "pack .qmltypes files so type information is available from the pack")
+var followSymlinks = flag.Bool("follow-symlinks", false, "walk the targets of symbolic links instead of skipping them")
+var noTimestamp = flag.Bool("no-timestamp", false, "leave ResourcesBuiltAt empty so the generated file is reproducible")
+var compression = flag.Int("compress", 0, "zlib compression level for packed files, from 1 to 9 (0 disables compression)")
+var remaps stringsFlag
+var excludes stringsFlag
+var groups stringsFlag
+
+func init() {
+	flag.Var(&remaps, "remap", "expose packed files under the old path prefix as `old=new` instead (may be repeated)")
+	flag.Var(&excludes, "exclude", "skip files and directories matching the `glob` pattern (may be repeated)")
+	flag.Var(&groups, "group", "pack the comma-separated paths into a group that is loaded on demand, as `name:path,...` (may be repeated)")
+}
+
+// stringsFlag is a flag.Value that accumulates the values of a repeated flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
 
 // XXX any changes made here should be copied exactly into its counterpart in the template below
-func qrcPackResources(subdirs []string) ([]byte, error) {
+func qrcPackResources(subdirs []string, includeQmldir, includeQmltypes, followSymlinks bool, compression int, remaps, excludes []string) (*qml.ResourcesPacker, error) {
 
 	type qrcFile struct {
 		Alias string        `xml:"alias,attr"`
@@ -118,11 +324,12 @@ func qrcPackResources(subdirs []string) ([]byte, error) {
 
 	type qrcResource struct {
 		Prefix string        `xml:"prefix,attr"`
+		Lang   string        `xml:"lang,attr"`
 		Files  []qrcFile    `xml:"file"`
 	}
 
 	type qrcQrcFile struct {
-		XMLName   xml.Name      `xml:"RCC"`
+		XMLName   xml.Name
 		Resources []qrcResource `xml:"qresource"`
 	}
 
@@ -137,16 +344,27 @@ func qrcPackResources(subdirs []string) ([]byte, error) {
 		qrc := qrcQrcFile{}
 		err = xml.Unmarshal(data, &qrc)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if qrc.XMLName.Local != "RCC" {
+			return nil, fmt.Errorf("%s: root element is <%s> rather than <RCC>", name, qrc.XMLName.Local)
 		}
 
 		out := make(map[string]string)
 
 		for _, resource := range qrc.Resources {
 			for _, file := range resource.Files {
-				label := filepath.Join(resource.Prefix, file.Name)
+				label := file.Name
 				if file.Alias != "" {
-					label = filepath.Join(resource.Prefix, file.Alias)
+					label = file.Alias
+				}
+				if resource.Lang != "" {
+					// The engine's file selector picks "+lang" variants matching its locale.
+					label = filepath.Join(filepath.Dir(label), "+"+resource.Lang, filepath.Base(label))
+				}
+				label = filepath.Join(resource.Prefix, label)
+				if _, ok := out[label]; ok {
+					return nil, fmt.Errorf("%s: resource %s is defined more than once", name, label)
 				}
 				out[label] = filepath.Join(dir, file.Name)
 			}
@@ -154,54 +372,278 @@ func qrcPackResources(subdirs []string) ([]byte, error) {
 		return out, nil
 	}
 
+	// qrcParseQmldir returns the labels of the .qml and .js files
+	// referenced by the qmldir file packed under the provided label.
+	qrcParseQmldir := func(label string, data []byte) []string {
+		var refs []string
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			switch fields[0] {
+			case "module", "plugin", "classname", "typeinfo", "depends":
+				continue
+			}
+			file := fields[len(fields)-1]
+			if ext := path.Ext(file); ext == ".qml" || ext == ".js" {
+				refs = append(refs, path.Join(path.Dir(label), file))
+			}
+		}
+		return refs
+	}
+
+	// qrcRemap rewrites the label prefix according to the first
+	// matching "from=to" entry in remaps.
+	qrcRemap := func(label string) string {
+		for _, remap := range remaps {
+			i := strings.Index(remap, "=")
+			from, to := strings.Trim(remap[:i], "/"), strings.Trim(remap[i+1:], "/")
+			if from == "" {
+				return path.Join(to, label)
+			}
+			if label == from || strings.HasPrefix(label, from+"/") {
+				return strings.TrimPrefix(path.Join(to, label[len(from):]), "/")
+			}
+		}
+		return label
+	}
+
+	// qrcMatch reports whether the name elements match the pattern elements,
+	// with "**" matching any number of elements.
+	var qrcMatch func(pattern, name []string) bool
+	qrcMatch = func(pattern, name []string) bool {
+		for len(pattern) > 0 {
+			if pattern[0] == "**" {
+				for i := 0; i <= len(name); i++ {
+					if qrcMatch(pattern[1:], name[i:]) {
+						return true
+					}
+				}
+				return false
+			}
+			if len(name) == 0 {
+				return false
+			}
+			if ok, _ := path.Match(pattern[0], name[0]); !ok {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+		return len(name) == 0
+	}
+
+	// qrcExcluded reports whether the slash-separated name, relative to
+	// the walked root, matches any of the exclude patterns. Patterns without
+	// a slash are also matched against the base name at any depth.
+	qrcExcluded := func(name string) bool {
+		for _, exclude := range excludes {
+			if qrcMatch(strings.Split(exclude, "/"), strings.Split(name, "/")) {
+				return true
+			}
+			if !strings.Contains(exclude, "/") {
+				if ok, _ := path.Match(exclude, path.Base(name)); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
 	var rp qml.ResourcesPacker
+	rp.SetCompression(compression)
 
-	for _, subdir := range subdirs {
-		err := filepath.Walk(subdir, func(name string, info os.FileInfo, err error) error {
+	packed := make(map[string]string)
+	qmldirRefs := make(map[string][]string)
+	qrcAdd := func(name, file string) error {
+		label := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+		alias := qrcRemap(label)
+		if prev, ok := packed[alias]; ok {
+			return fmt.Errorf("cannot add %s as %s: already used by %s", label, alias, prev)
+		}
+		// The file is only read when the resources are written out.
+		if err := rp.AddReader(alias, &qrcFileReader{name: file}); err != nil {
+			return err
+		}
+		packed[alias] = label
+		if path.Base(alias) == "qmldir" {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			qmldirRefs[alias] = qrcParseQmldir(alias, data)
+		}
+		return nil
+	}
+
+	// qrcAncestors returns the directories holding file, up to the
+	// root of the filesystem.
+	qrcAncestors := func(file string) ([]os.FileInfo, error) {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		var infos []os.FileInfo
+		for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+			info, err := os.Stat(dir)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+			if dir == filepath.Dir(dir) {
+				return infos, nil
+			}
+		}
+	}
+
+	// qrcWalk packs the files under dir, which is reached via the path
+	// prefix while walking subdir. The ancestors hold the directories
+	// walked through symbolic links so far, to detect cycles.
+	var qrcWalk func(subdir, dir, prefix string, ancestors []os.FileInfo) error
+	qrcWalk = func(subdir, dir, prefix string, ancestors []os.FileInfo) error {
+		return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, file)
 			if err != nil {
 				return err
 			}
+			name := filepath.Join(prefix, rel)
+
+			if rel, err := filepath.Rel(subdir, name); err != nil {
+				return err
+			} else if rel != "." && qrcExcluded(filepath.ToSlash(rel)) {
+				fmt.Printf("Excluding: %s\n", name)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				if !followSymlinks {
+					fmt.Printf("Warning: skipping symbolic link: %s\n", name)
+					return nil
+				}
+				target, err := os.Stat(file)
+				if err != nil {
+					return err
+				}
+				if target.IsDir() {
+					parents, err := qrcAncestors(file)
+					if err != nil {
+						return err
+					}
+					ancestors := append(ancestors[:len(ancestors):len(ancestors)], parents...)
+					for _, ancestor := range ancestors {
+						if os.SameFile(target, ancestor) {
+							fmt.Printf("Warning: skipping symbolic link cycle: %s\n", name)
+							return nil
+						}
+					}
+					resolved, err := filepath.EvalSymlinks(file)
+					if err != nil {
+						return err
+					}
+					return qrcWalk(subdir, resolved, name, ancestors)
+				}
+				info = target
+			}
 
 			ext := filepath.Ext(name)
 			switch true {
 			case info.IsDir():
-			case info.Name() == "qmldir":
+			case info.Name() == "qmldir" && !includeQmldir:
 				fmt.Printf("Skipping file: %s\n", name)
-			case ext == ".qmltypes":
+			case ext == ".qmltypes" && !includeQmltypes:
 				fmt.Printf("Skipping file: %s\n", name)
 			case ext == ".pri":
 				fmt.Printf("Skipping file: %s\n", name)
 			case ext == ".qrc":
 				fmt.Printf("Processing file: %s\n", name)
-				files, err := qrcParseQrc(name)
+				files, err := qrcParseQrc(file)
 				if err != nil {
 					return err
 				}
-				for label, filename := range files {
-					data, err := ioutil.ReadFile(filename)
-					if err != nil {
+				labels := make([]string, 0, len(files))
+				for label := range files {
+					labels = append(labels, label)
+				}
+				sort.Strings(labels)
+				for _, label := range labels {
+					fmt.Printf("\tAdding: %s\n", label)
+					if err := qrcAdd(label, files[label]); err != nil {
 						return err
 					}
-					fmt.Printf("\tAdding: %s\n", label)
-					rp.Add(label, data)
 				}
 				fmt.Println("\tDone.")
 			default:
-				data, err := ioutil.ReadFile(name)
-				if err != nil {
+				fmt.Printf("Adding: %s\n", name)
+				if err := qrcAdd(name, file); err != nil {
 					return err
 				}
-				fmt.Printf("Adding: %s\n", name)
-				rp.Add(filepath.ToSlash(name), data)
 			}
 			return nil
 		})
-		if err != nil {
+	}
+
+	for _, subdir := range subdirs {
+		if err := qrcWalk(subdir, subdir, subdir, nil); err != nil {
 			return nil, err
 		}
 	}
 
-	return rp.Pack().Bytes(), nil
+	qmldirs := make([]string, 0, len(qmldirRefs))
+	for label := range qmldirRefs {
+		qmldirs = append(qmldirs, label)
+	}
+	sort.Strings(qmldirs)
+	for _, label := range qmldirs {
+		for _, ref := range qmldirRefs[label] {
+			if _, ok := packed[ref]; !ok {
+				fmt.Printf("Warning: %s references %s, which is not being packed\n", label, ref)
+			}
+		}
+	}
+
+	return &rp, nil
+}
+
+// qrcFileReader reads the file at name, opening it on the first read and
+// closing it again at EOF, so that packing many files doesn't hold them
+// all open. Seeking back to the start makes it read the file again.
+type qrcFileReader struct {
+	name string
+	f    *os.File
+}
+
+func (r *qrcFileReader) Read(p []byte) (int, error) {
+	if r.f == nil {
+		f, err := os.Open(r.name)
+		if err != nil {
+			return 0, err
+		}
+		r.f = f
+	}
+	n, err := r.f.Read(p)
+	if err == io.EOF {
+		r.f.Close()
+		r.f = nil
+	}
+	return n, err
+}
+
+func (r *qrcFileReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence == io.SeekEnd || whence == io.SeekCurrent && r.f != nil {
+		return 0, fmt.Errorf("cannot seek within %s", r.name)
+	}
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	return 0, nil
 }
 
 func main() {
@@ -217,40 +659,422 @@ func main() {
 }
 
 func run() error {
-	subdirs := flag.Args()
-	if len(subdirs) == 0 {
+	if *compression < 0 || *compression > 9 {
+		return fmt.Errorf("invalid -compress level %d; must be from 1 to 9, or 0 to disable compression", *compression)
+	}
+
+	subdirs, err := expandPaths(flag.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+	if len(subdirs) == 0 && len(groups) == 0 {
 		return fmt.Errorf("must provide at least one path")
 	}
 
-	resdata, err := qrcPackResources(subdirs)
+	named := make(map[string]bool)
+	var resgroups []resourceGroup
+	for _, value := range groups {
+		group, err := parseGroup(value)
+		if err != nil {
+			return err
+		}
+		if named[group.Name] {
+			return fmt.Errorf("resource group %q is defined more than once", value[:strings.Index(value, ":")])
+		}
+		named[group.Name] = true
+		if group.SubDirs, err = expandPaths(group.SubDirs, os.Stdin); err != nil {
+			return err
+		}
+		resgroups = append(resgroups, group)
+	}
+
+	for _, remap := range remaps {
+		if !strings.Contains(remap, "=") {
+			return fmt.Errorf("invalid -remap value %q; must be in the form old=new", remap)
+		}
+	}
+
+	rp, err := qrcPackResources(subdirs, *includeQmldir, *includeQmltypes, *followSymlinks, *compression, remaps, excludes)
+	if err != nil {
+		return err
+	}
+
+	for i := range resgroups {
+		group := &resgroups[i]
+		fmt.Printf("Packing resource group: %s\n", group.Name)
+		group.Packer, err = qrcPackResources(group.SubDirs, *includeQmldir, *includeQmltypes, *followSymlinks, *compression, remaps, excludes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *check {
+		return checkOutput(*output, rp, resgroups)
+	}
+
+	pkgname, err := outputPackageName(*output)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Create("qrc.go")
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(*output)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
+	builtAt := ""
+	if !*noTimestamp {
+		builtAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
 	data := templateData{
-		PackageName:   *packageName,
-		SubDirs:       subdirs,
-		ResourcesData: resdata,
+		PackageName:     pkgname,
+		SubDirs:         subdirs,
+		IncludeQmldir:   *includeQmldir,
+		IncludeQmltypes: *includeQmltypes,
+		FollowSymlinks:  *followSymlinks,
+		Compression:     *compression,
+		Remaps:          remaps,
+		Excludes:        excludes,
+		BuiltAt:         builtAt,
+		Groups:          resgroups,
+	}
+
+	// The resources data is streamed into the file as it's packed.
+	w := bufio.NewWriter(f)
+	if err := tmpl.Execute(w, data); err != nil {
+		return err
+	}
+	if err := writeResourcesData(w, "qrcResourcesData", rp); err != nil {
+		return err
+	}
+	for _, group := range resgroups {
+		if err := writeResourcesData(w, "qrcResourcesData"+group.Name, group.Packer); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
 
-	// $GOPACKAGE is set automatically by go generate.
-	if pkgname := os.Getenv("GOPACKAGE"); pkgname != "" {
-		data.PackageName = pkgname
+	// The hash and the manifest are taken from the data just written,
+	// rather than from a copy held in memory while packing.
+	resdata, err := readResourcesData(*output, "qrcResourcesData")
+	if err != nil {
+		return err
+	}
+	r, err := qml.ParseResources(resdata)
+	if err != nil {
+		return err
 	}
+	if *manifest != "" {
+		if err := writeManifest(*manifest, r); err != nil {
+			return err
+		}
+	}
+	data.ResourcesHash = r.Hash()
+	return stampTmpl.Execute(f, data)
+}
 
-	return tmpl.Execute(f, data)
+// writeResourcesData writes to w the declaration of a variable with the
+// provided name holding the data of the resources in rp, as it's packed.
+func writeResourcesData(w io.Writer, varname string, rp *qml.ResourcesPacker) error {
+	fmt.Fprintf(w, "\n// %s holds the packed resources data.\nvar %s = \"", varname, varname)
+	if _, err := rp.WriteTo(quoteWriter{w}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\"\n")
+	return err
+}
+
+// quoteWriter writes the data written to it into w as the content of a Go
+// interpreted string literal. Bytes are escaped independently of each
+// other, so the result doesn't depend on how the data is split in writes.
+type quoteWriter struct {
+	w io.Writer
+}
+
+func (qw quoteWriter) Write(p []byte) (int, error) {
+	const hex = "0123456789abcdef"
+	buf := make([]byte, 0, len(p)*2)
+	for _, b := range p {
+		switch {
+		case b == '"' || b == '\\':
+			buf = append(buf, '\\', b)
+		case b == '\n':
+			buf = append(buf, '\\', 'n')
+		case b == '\t':
+			buf = append(buf, '\\', 't')
+		case b >= 0x20 && b < 0x7f:
+			buf = append(buf, b)
+		default:
+			buf = append(buf, '\\', 'x', hex[b>>4], hex[b&0xf])
+		}
+	}
+	if _, err := qw.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// expandPaths returns args with each "-" replaced by the paths read from
+// stdin and each "@file" replaced by the paths read from file.
+func expandPaths(args []string, stdin io.Reader) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		switch {
+		case arg == "-":
+			lines, err := readPaths(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read paths from standard input: %v", err)
+			}
+			paths = append(paths, lines...)
+		case strings.HasPrefix(arg, "@"):
+			f, err := os.Open(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			lines, err := readPaths(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read paths from %s: %v", arg[1:], err)
+			}
+			paths = append(paths, lines...)
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	return paths, nil
+}
+
+// readPaths returns the paths in r, one per line, skipping blank
+// lines and lines starting with "#".
+func readPaths(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// resourceGroup holds the resources packed for a -group flag.
+type resourceGroup struct {
+	Name    string
+	SubDirs []string
+	Packer  *qml.ResourcesPacker
+}
+
+// parseGroup parses a -group flag value in the form "name:path,...".
+// The group name is capitalized so it may be used in exported names.
+func parseGroup(value string) (resourceGroup, error) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return resourceGroup{}, fmt.Errorf("invalid -group value %q; must be in the form name:path,...", value)
+	}
+	name := value[:i]
+	for j, r := range name {
+		if !unicode.IsLetter(r) && (j == 0 || !unicode.IsDigit(r)) {
+			return resourceGroup{}, fmt.Errorf("invalid -group name %q; must be a letter followed by letters and digits", name)
+		}
+	}
+	if name == "" {
+		return resourceGroup{}, fmt.Errorf("invalid -group value %q; missing group name", value)
+	}
+	var subdirs []string
+	for _, subdir := range strings.Split(value[i+1:], ",") {
+		if subdir != "" {
+			subdirs = append(subdirs, subdir)
+		}
+	}
+	if len(subdirs) == 0 {
+		return resourceGroup{}, fmt.Errorf("invalid -group value %q; must provide at least one path", value)
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return resourceGroup{Name: string(r), SubDirs: subdirs}, nil
+}
+
+// checkOutput returns an error if the resources data embedded in the
+// previously generated file at filename differs from the data packed by
+// rp, or by the packer of any of the resource groups.
+func checkOutput(filename string, rp *qml.ResourcesPacker, resgroups []resourceGroup) error {
+	current, err := readResourcesData(filename, "qrcResourcesData")
+	if err != nil {
+		return err
+	}
+	if differs, err := packDiffers(rp, current); err != nil {
+		return err
+	} else if differs {
+		return fmt.Errorf("%s is stale; re-run genqrc to update it", filename)
+	}
+	for _, group := range resgroups {
+		current, err := readResourcesData(filename, "qrcResourcesData"+group.Name)
+		if err != nil {
+			return err
+		}
+		if differs, err := packDiffers(group.Packer, current); err != nil {
+			return err
+		} else if differs {
+			return fmt.Errorf("%s is stale for resource group %s; re-run genqrc to update it", filename, group.Name)
+		}
+	}
+	fmt.Printf("%s is up to date\n", filename)
+	return nil
+}
+
+// packDiffers reports whether the data packed by rp differs from data.
+// The packed data is compared as it's written rather than held in memory.
+func packDiffers(rp *qml.ResourcesPacker, data []byte) (bool, error) {
+	cw := &compareWriter{data: data}
+	_, err := rp.WriteTo(cw)
+	if err == errDiffers {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(cw.data) > 0, nil
+}
+
+var errDiffers = errors.New("packed data differs")
+
+// compareWriter fails with errDiffers unless the data written to it
+// matches the start of the data not yet compared.
+type compareWriter struct {
+	data []byte
+}
+
+func (cw *compareWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(cw.data, p) {
+		return 0, errDiffers
+	}
+	cw.data = cw.data[len(p):]
+	return len(p), nil
+}
+
+// readResourcesData returns the resources data embedded in the previously
+// generated file at filename, in the variable with the provided name.
+func readResourcesData(filename, varname string) ([]byte, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	ast.Inspect(f, func(node ast.Node) bool {
+		// The data is held in a package variable, or in a local variable
+		// of the init function in files generated by earlier versions.
+		var lhs, rhs []ast.Expr
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			lhs, rhs = node.Lhs, node.Rhs
+		case *ast.ValueSpec:
+			if len(node.Names) == 1 {
+				lhs, rhs = []ast.Expr{node.Names[0]}, node.Values
+			}
+		}
+		if len(lhs) != 1 || len(rhs) != 1 {
+			return data == nil
+		}
+		ident, ok := lhs[0].(*ast.Ident)
+		lit, ok2 := rhs[0].(*ast.BasicLit)
+		if ok && ok2 && ident.Name == varname && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				data = []byte(s)
+			}
+		}
+		return data == nil
+	})
+	if data == nil {
+		return nil, fmt.Errorf("cannot find %s in %s; was it generated by genqrc?", varname, filename)
+	}
+	return data, nil
+}
+
+// writeManifest writes to filename a listing of the resources in r, one
+// per line, with the uncompressed size and the resource path separated by
+// a tab. The last line holds the total size.
+func writeManifest(filename string, r *qml.Resources) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	total := 0
+	for _, info := range r.List() {
+		fmt.Fprintf(f, "%d\t%s\n", info.Size, info.Path)
+		total += info.Size
+	}
+	if _, err := fmt.Fprintf(f, "%d\t(total)\n", total); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// outputPackageName returns the package name for the generated file at
+// output. An explicit -package flag always wins. Otherwise the file goes
+// into the package being generated by go generate, when it's written to
+// the current directory, or into a package named after its directory.
+func outputPackageName(output string) (string, error) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "package" {
+			explicit = true
+		}
+	})
+	if explicit {
+		return *packageName, nil
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if dir == wd {
+		// $GOPACKAGE is set automatically by go generate.
+		if pkgname := os.Getenv("GOPACKAGE"); pkgname != "" {
+			return pkgname, nil
+		}
+		return *packageName, nil
+	}
+
+	name := []rune(strings.ToLower(filepath.Base(dir)))
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || unicode.IsDigit(name[0]) {
+		name = append([]rune{'_'}, name...)
+	}
+	return string(name), nil
 }
 
 type templateData struct {
-	PackageName   string
-	SubDirs       []string
-	ResourcesData []byte
+	PackageName     string
+	SubDirs         []string
+	IncludeQmldir   bool
+	IncludeQmltypes bool
+	FollowSymlinks  bool
+	Compression     int
+	Remaps          []string
+	Excludes        []string
+	ResourcesHash   string
+	BuiltAt         string
+	Groups          []resourceGroup
 }
 
 func buildTemplate(name, content string) *template.Template {
@@ -262,48 +1086,68 @@ var tmpl = buildTemplate("qrc.go", `package {{.PackageName}}
 // This file is automatically generated by gopkg.in/qml.v1/cmd/genqrc
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"fmt"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"encoding/xml"
 
 	"gopkg.in/qml.v1"
 )
-
+{{range .Groups}}
+// Resources{{.Name}} holds the resources of the {{.Name}} group, which are
+// not loaded automatically. Load them with qml.LoadResources when needed.
+var Resources{{.Name}} *qml.Resources
+{{end}}
 func init() {
-	qrcResourcesData := {{printf "%q" .ResourcesData}}
+	qml.LoadResources(qrcParseResources(qrcResourcesData, {{printf "%#v" .SubDirs}}))
+{{range .Groups}}
+	Resources{{.Name}} = qrcParseResources(qrcResourcesData{{.Name}}, {{printf "%#v" .SubDirs}})
+{{end}}}
 
+// qrcParseResources parses the bundled resources data, or repacks the
+// resources under subdirs if the QRC_REPACK environment variable is set.
+func qrcParseResources(data string, subdirs []string) *qml.Resources {
 	if os.Getenv("QRC_REPACK") == "1" {
 		fmt.Println("Repacking resources")
-		data, err := qrcPackResources({{printf "%#v" .SubDirs}})
+		rp, err := qrcPackResources(subdirs, {{.IncludeQmldir}}, {{.IncludeQmltypes}}, {{.FollowSymlinks}}, {{.Compression}}, {{printf "%#v" .Remaps}}, {{printf "%#v" .Excludes}})
 		if err != nil {
 			panic("cannot repack qrc resources: " + err.Error())
 		}
-		qrcResourcesData = string(data)
+		var buf bytes.Buffer
+		if _, err := rp.WriteTo(&buf); err != nil {
+			panic("cannot repack qrc resources: " + err.Error())
+		}
+		data = buf.String()
 	}
-	r, err := qml.ParseResourcesString(qrcResourcesData)
+	r, err := qml.ParseResourcesString(data)
 	if err != nil {
 		panic("cannot parse bundled resources data: " + err.Error())
 	}
-	qml.LoadResources(r)
+	return r
 }
 
-func qrcPackResources(subdirs []string) ([]byte, error) {
+func qrcPackResources(subdirs []string, includeQmldir, includeQmltypes, followSymlinks bool, compression int, remaps, excludes []string) (*qml.ResourcesPacker, error) {
 
 	type qrcFile struct {
-		Alias string        ` + "`xml:\"alias,attr\"`" + `
-		Name  string        ` + "`xml:\",chardata\"`" + `
+		Alias string        `+"`xml:\"alias,attr\"`"+`
+		Name  string        `+"`xml:\",chardata\"`"+`
 	}
 
 	type qrcResource struct {
-		Prefix string        ` + "`xml:\"prefix,attr\"`" + `
-		Files  []qrcFile    ` + "`xml:\"file\"`" + `
+		Prefix string        `+"`xml:\"prefix,attr\"`"+`
+		Lang   string        `+"`xml:\"lang,attr\"`"+`
+		Files  []qrcFile    `+"`xml:\"file\"`"+`
 	}
 
 	type qrcQrcFile struct {
-		XMLName   xml.Name      ` + "`xml:\"RCC\"`" + `
-		Resources []qrcResource ` + "`xml:\"qresource\"`" + `
+		XMLName   xml.Name
+		Resources []qrcResource `+"`xml:\"qresource\"`"+`
 	}
 
 	qrcParseQrc := func(name string) (map[string]string, error) {
@@ -317,16 +1161,27 @@ func qrcPackResources(subdirs []string) ([]byte, error) {
 		qrc := qrcQrcFile{}
 		err = xml.Unmarshal(data, &qrc)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if qrc.XMLName.Local != "RCC" {
+			return nil, fmt.Errorf("%s: root element is <%s> rather than <RCC>", name, qrc.XMLName.Local)
 		}
 
 		out := make(map[string]string)
 
 		for _, resource := range qrc.Resources {
 			for _, file := range resource.Files {
-				label := filepath.Join(resource.Prefix, file.Name)
+				label := file.Name
 				if file.Alias != "" {
-					label = filepath.Join(resource.Prefix, file.Alias)
+					label = file.Alias
+				}
+				if resource.Lang != "" {
+					// The engine's file selector picks "+lang" variants matching its locale.
+					label = filepath.Join(filepath.Dir(label), "+"+resource.Lang, filepath.Base(label))
+				}
+				label = filepath.Join(resource.Prefix, label)
+				if _, ok := out[label]; ok {
+					return nil, fmt.Errorf("%s: resource %s is defined more than once", name, label)
 				}
 				out[label] = filepath.Join(dir, file.Name)
 			}
@@ -334,53 +1189,292 @@ func qrcPackResources(subdirs []string) ([]byte, error) {
 		return out, nil
 	}
 
+	// qrcParseQmldir returns the labels of the .qml and .js files
+	// referenced by the qmldir file packed under the provided label.
+	qrcParseQmldir := func(label string, data []byte) []string {
+		var refs []string
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			switch fields[0] {
+			case "module", "plugin", "classname", "typeinfo", "depends":
+				continue
+			}
+			file := fields[len(fields)-1]
+			if ext := path.Ext(file); ext == ".qml" || ext == ".js" {
+				refs = append(refs, path.Join(path.Dir(label), file))
+			}
+		}
+		return refs
+	}
+
+	// qrcRemap rewrites the label prefix according to the first
+	// matching "from=to" entry in remaps.
+	qrcRemap := func(label string) string {
+		for _, remap := range remaps {
+			i := strings.Index(remap, "=")
+			from, to := strings.Trim(remap[:i], "/"), strings.Trim(remap[i+1:], "/")
+			if from == "" {
+				return path.Join(to, label)
+			}
+			if label == from || strings.HasPrefix(label, from+"/") {
+				return strings.TrimPrefix(path.Join(to, label[len(from):]), "/")
+			}
+		}
+		return label
+	}
+
+	// qrcMatch reports whether the name elements match the pattern elements,
+	// with "**" matching any number of elements.
+	var qrcMatch func(pattern, name []string) bool
+	qrcMatch = func(pattern, name []string) bool {
+		for len(pattern) > 0 {
+			if pattern[0] == "**" {
+				for i := 0; i <= len(name); i++ {
+					if qrcMatch(pattern[1:], name[i:]) {
+						return true
+					}
+				}
+				return false
+			}
+			if len(name) == 0 {
+				return false
+			}
+			if ok, _ := path.Match(pattern[0], name[0]); !ok {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+		return len(name) == 0
+	}
+
+	// qrcExcluded reports whether the slash-separated name, relative to
+	// the walked root, matches any of the exclude patterns. Patterns without
+	// a slash are also matched against the base name at any depth.
+	qrcExcluded := func(name string) bool {
+		for _, exclude := range excludes {
+			if qrcMatch(strings.Split(exclude, "/"), strings.Split(name, "/")) {
+				return true
+			}
+			if !strings.Contains(exclude, "/") {
+				if ok, _ := path.Match(exclude, path.Base(name)); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
 	var rp qml.ResourcesPacker
+	rp.SetCompression(compression)
 
-	for _, subdir := range subdirs {
-		err := filepath.Walk(subdir, func(name string, info os.FileInfo, err error) error {
+	packed := make(map[string]string)
+	qmldirRefs := make(map[string][]string)
+	qrcAdd := func(name, file string) error {
+		label := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+		alias := qrcRemap(label)
+		if prev, ok := packed[alias]; ok {
+			return fmt.Errorf("cannot add %s as %s: already used by %s", label, alias, prev)
+		}
+		// The file is only read when the resources are written out.
+		if err := rp.AddReader(alias, &qrcFileReader{name: file}); err != nil {
+			return err
+		}
+		packed[alias] = label
+		if path.Base(alias) == "qmldir" {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			qmldirRefs[alias] = qrcParseQmldir(alias, data)
+		}
+		return nil
+	}
+
+	// qrcAncestors returns the directories holding file, up to the
+	// root of the filesystem.
+	qrcAncestors := func(file string) ([]os.FileInfo, error) {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		var infos []os.FileInfo
+		for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+			info, err := os.Stat(dir)
 			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+			if dir == filepath.Dir(dir) {
+				return infos, nil
+			}
+		}
+	}
+
+	// qrcWalk packs the files under dir, which is reached via the path
+	// prefix while walking subdir. The ancestors hold the directories
+	// walked through symbolic links so far, to detect cycles.
+	var qrcWalk func(subdir, dir, prefix string, ancestors []os.FileInfo) error
+	qrcWalk = func(subdir, dir, prefix string, ancestors []os.FileInfo) error {
+		return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			name := filepath.Join(prefix, rel)
+
+			if rel, err := filepath.Rel(subdir, name); err != nil {
 				return err
+			} else if rel != "." && qrcExcluded(filepath.ToSlash(rel)) {
+				fmt.Printf("Excluding: %s\n", name)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				if !followSymlinks {
+					fmt.Printf("Warning: skipping symbolic link: %s\n", name)
+					return nil
+				}
+				target, err := os.Stat(file)
+				if err != nil {
+					return err
+				}
+				if target.IsDir() {
+					parents, err := qrcAncestors(file)
+					if err != nil {
+						return err
+					}
+					ancestors := append(ancestors[:len(ancestors):len(ancestors)], parents...)
+					for _, ancestor := range ancestors {
+						if os.SameFile(target, ancestor) {
+							fmt.Printf("Warning: skipping symbolic link cycle: %s\n", name)
+							return nil
+						}
+					}
+					resolved, err := filepath.EvalSymlinks(file)
+					if err != nil {
+						return err
+					}
+					return qrcWalk(subdir, resolved, name, ancestors)
+				}
+				info = target
 			}
 
 			ext := filepath.Ext(name)
 			switch true {
 			case info.IsDir():
-			case info.Name() == "qmldir":
+			case info.Name() == "qmldir" && !includeQmldir:
 				fmt.Printf("Skipping file: %s\n", name)
-			case ext == ".qmltypes":
+			case ext == ".qmltypes" && !includeQmltypes:
 				fmt.Printf("Skipping file: %s\n", name)
 			case ext == ".pri":
 				fmt.Printf("Skipping file: %s\n", name)
 			case ext == ".qrc":
 				fmt.Printf("Processing file: %s\n", name)
-				files, err := qrcParseQrc(name)
+				files, err := qrcParseQrc(file)
 				if err != nil {
 					return err
 				}
-				for label, filename := range files {
-					data, err := ioutil.ReadFile(filename)
-					if err != nil {
+				labels := make([]string, 0, len(files))
+				for label := range files {
+					labels = append(labels, label)
+				}
+				sort.Strings(labels)
+				for _, label := range labels {
+					fmt.Printf("\tAdding: %s\n", label)
+					if err := qrcAdd(label, files[label]); err != nil {
 						return err
 					}
-					fmt.Printf("\tAdding: %s\n", label)
-					rp.Add(label, data)
 				}
 				fmt.Println("\tDone.")
 			default:
-				data, err := ioutil.ReadFile(name)
-				if err != nil {
+				fmt.Printf("Adding: %s\n", name)
+				if err := qrcAdd(name, file); err != nil {
 					return err
 				}
-				fmt.Printf("Adding: %s\n", name)
-				rp.Add(filepath.ToSlash(name), data)
 			}
 			return nil
 		})
-		if err != nil {
+	}
+
+	for _, subdir := range subdirs {
+		if err := qrcWalk(subdir, subdir, subdir, nil); err != nil {
 			return nil, err
 		}
 	}
 
-	return rp.Pack().Bytes(), nil
+	qmldirs := make([]string, 0, len(qmldirRefs))
+	for label := range qmldirRefs {
+		qmldirs = append(qmldirs, label)
+	}
+	sort.Strings(qmldirs)
+	for _, label := range qmldirs {
+		for _, ref := range qmldirRefs[label] {
+			if _, ok := packed[ref]; !ok {
+				fmt.Printf("Warning: %s references %s, which is not being packed\n", label, ref)
+			}
+		}
+	}
+
+	return &rp, nil
+}
+
+// qrcFileReader reads the file at name, opening it on the first read and
+// closing it again at EOF, so that packing many files doesn't hold them
+// all open. Seeking back to the start makes it read the file again.
+type qrcFileReader struct {
+	name string
+	f    *os.File
+}
+
+func (r *qrcFileReader) Read(p []byte) (int, error) {
+	if r.f == nil {
+		f, err := os.Open(r.name)
+		if err != nil {
+			return 0, err
+		}
+		r.f = f
+	}
+	n, err := r.f.Read(p)
+	if err == io.EOF {
+		r.f.Close()
+		r.f = nil
+	}
+	return n, err
+}
+
+func (r *qrcFileReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence == io.SeekEnd || whence == io.SeekCurrent && r.f != nil {
+		return 0, fmt.Errorf("cannot seek within %s", r.name)
+	}
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	return 0, nil
 }
 `)
+
+// stampTmpl is executed after the resources data is written out, as the
+// generated constants depend on it.
+var stampTmpl = buildTemplate("stamp", `
+// qrcResourcesHash is the qml.Resources.Hash of the packed resources.
+const qrcResourcesHash = {{printf "%q" .ResourcesHash}}
+
+// ResourcesDigest is the SHA-256 digest of the packed resources, as
+// computed by qml.Resources.Hash.
+const ResourcesDigest = "sha256:" + qrcResourcesHash
+
+// ResourcesBuiltAt is the time the resources were packed, in RFC 3339
+// format, or empty if genqrc was run with -no-timestamp.
+const ResourcesBuiltAt = {{printf "%q" .BuiltAt}}
+`)
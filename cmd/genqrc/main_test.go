@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func packedPaths(t *testing.T, subdir string) []string {
+	rp, err := qrcPackResources([]string{subdir}, true, false, false, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot pack %s: %v", subdir, err)
+	}
+	r := rp.Pack()
+	var paths []string
+	for _, info := range r.List() {
+		paths = append(paths, info.Path)
+	}
+	return paths
+}
+
+func TestQrcMultiplePrefixes(t *testing.T) {
+	paths := packedPaths(t, "testdata/multi/multi.qrc")
+	want := []string{"README.txt", "images/logo.txt", "ui/main.qml"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("packed %q, want %q", paths, want)
+	}
+}
+
+func TestQrcLang(t *testing.T) {
+	paths := packedPaths(t, "testdata/lang/lang.qrc")
+	want := []string{"i18n/+de_DE/hello.txt", "i18n/+fr/hello.txt", "i18n/hello.txt"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("packed %q, want %q", paths, want)
+	}
+}
+
+func TestQrcNotRCC(t *testing.T) {
+	_, err := qrcPackResources([]string{"testdata/notrcc.qrc"}, true, false, false, 0, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "root element is <qresources> rather than <RCC>") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	stdin := strings.NewReader("a.qml\n\n# comment\nimages\r\n")
+	paths, err := expandPaths([]string{"first.qrc", "-", "@testdata/paths.txt", "last"}, stdin)
+	if err != nil {
+		t.Fatalf("cannot expand paths: %v", err)
+	}
+	want := []string{"first.qrc", "a.qml", "images", "testdata/multi/main.qml", "testdata/lang", "last"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expanded to %q, want %q", paths, want)
+	}
+}
+
+func TestExpandPathsMissingFile(t *testing.T) {
+	_, err := expandPaths([]string{"@testdata/missing.txt"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing.txt") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestQrcIncludeQmltypes(t *testing.T) {
+	for _, include := range []bool{false, true} {
+		rp, err := qrcPackResources([]string{"testdata/types"}, true, include, false, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("cannot pack: %v", err)
+		}
+		r := rp.Pack()
+		var paths []string
+		for _, info := range r.List() {
+			paths = append(paths, info.Path)
+		}
+		want := []string{"testdata/types/Widget.qml"}
+		if include {
+			want = append(want, "testdata/types/plugins.qmltypes")
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("with include-qmltypes=%v packed %q, want %q", include, paths, want)
+		}
+	}
+}
+
+func TestResourcesDigest(t *testing.T) {
+	rp, err := qrcPackResources([]string{"testdata/multi/multi.qrc"}, true, false, false, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot pack: %v", err)
+	}
+	r := rp.Pack()
+	var buf bytes.Buffer
+	err = stampTmpl.Execute(&buf, templateData{PackageName: "main", ResourcesHash: r.Hash()})
+	if err != nil {
+		t.Fatalf("cannot execute template: %v", err)
+	}
+	for _, want := range []string{
+		"const qrcResourcesHash = \"" + r.Hash() + "\"\n",
+		"const ResourcesDigest = \"sha256:\" + qrcResourcesHash\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("generated file is missing %q", want)
+		}
+	}
+}
+
+func TestGenerateAndCheck(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "genqrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	defer func(o string, c bool) { *output, *check = o, c }(*output, *check)
+	*output = filepath.Join(tmpdir, "qrc.go")
+	if err := flag.CommandLine.Parse([]string{"testdata/multi/multi.qrc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(); err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+
+	rp, err := qrcPackResources([]string{"testdata/multi/multi.qrc"}, true, false, false, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot pack: %v", err)
+	}
+	want := rp.Pack()
+	data, err := readResourcesData(*output, "qrcResourcesData")
+	if err != nil {
+		t.Fatalf("cannot read generated data: %v", err)
+	}
+	if !bytes.Equal(data, want.Bytes()) {
+		t.Fatalf("generated data differs from packed data")
+	}
+	source, err := ioutil.ReadFile(*output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(source), "const qrcResourcesHash = \""+want.Hash()+"\"\n") {
+		t.Fatalf("generated file is missing the resources hash")
+	}
+
+	*check = true
+	if err := run(); err != nil {
+		t.Fatalf("fresh output considered stale: %v", err)
+	}
+	stale := strings.Replace(string(source), "qrcResourcesData = \"qres", "qrcResourcesData = \"qreS", 1)
+	if err := ioutil.WriteFile(*output, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(); err == nil || !strings.Contains(err.Error(), "is stale") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestQuoteWriter(t *testing.T) {
+	data := "qres\x00\x01\"\\\n\t\xffÃ£o"
+	var buf bytes.Buffer
+	w := quoteWriter{&buf}
+	for i := 0; i < len(data); i++ {
+		w.Write([]byte{data[i]})
+	}
+	got, err := strconv.Unquote(`"` + buf.String() + `"`)
+	if err != nil || got != data {
+		t.Fatalf("quoted %q as %s (unquoted %q, err %v)", data, buf.String(), got, err)
+	}
+}
+
+func TestQrcSymlinks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "genqrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	for _, dir := range []string{"assets/shared", "ui"} {
+		if err := os.MkdirAll(filepath.Join(tmpdir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"assets/shared/a.qml", "ui/main.qml"} {
+		if err := ioutil.WriteFile(filepath.Join(tmpdir, file), []byte("Item {}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../assets/shared", filepath.Join(tmpdir, "ui/shared")); err != nil {
+		t.Skipf("cannot create symbolic link: %v", err)
+	}
+	for link, target := range map[string]string{"ui/self": ".", "ui/up": ".."} {
+		if err := os.Symlink(target, filepath.Join(tmpdir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ui := filepath.Join(tmpdir, "ui")
+	prefix := strings.TrimPrefix(filepath.ToSlash(ui), "/") + "/"
+	for _, follow := range []bool{false, true} {
+		rp, err := qrcPackResources([]string{ui}, true, false, follow, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("cannot pack: %v", err)
+		}
+		r := rp.Pack()
+		var paths []string
+		for _, info := range r.List() {
+			paths = append(paths, strings.TrimPrefix(info.Path, prefix))
+		}
+		want := []string{"main.qml"}
+		if follow {
+			want = append(want, "shared/a.qml")
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("with follow-symlinks=%v packed %q, want %q", follow, paths, want)
+		}
+	}
+}
+
+func TestInvalidCompression(t *testing.T) {
+	defer func(level int) { *compression = level }(*compression)
+	for _, level := range []int{-2, -1, 10, 42} {
+		*compression = level
+		err := run()
+		if err == nil || !strings.Contains(err.Error(), "invalid -compress level") {
+			t.Fatalf("-compress %d: unexpected error: %v", level, err)
+		}
+	}
+}
+
+func TestParseGroup(t *testing.T) {
+	group, err := parseGroup("tutorial:tutorial,images/tutorial.png,")
+	if err != nil {
+		t.Fatalf("cannot parse group: %v", err)
+	}
+	if group.Name != "Tutorial" || !reflect.DeepEqual(group.SubDirs, []string{"tutorial", "images/tutorial.png"}) {
+		t.Fatalf("unexpected group: %#v", group)
+	}
+	for _, value := range []string{"tutorial", ":ui", "2d:ui", "my-group:ui", "tutorial:"} {
+		if _, err := parseGroup(value); err == nil {
+			t.Fatalf("parseGroup(%q) did not fail", value)
+		}
+	}
+}
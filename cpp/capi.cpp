@@ -4,6 +4,27 @@
 #include <QtQml>
 #include <QDebug>
 #include <QQuickImageProvider>
+#include <QScreen>
+#include <QPainter>
+#include <QClipboard>
+#include <QPixmapCache>
+#include <QCursor>
+#if QT_VERSION >= QT_VERSION_CHECK(5, 8, 0)
+#include <QSGRendererInterface>
+#endif
+#include <QSettings>
+#include <QMimeData>
+#include <QFontDatabase>
+#include <QDropEvent>
+#include <QNetworkAccessManager>
+#include <QNetworkProxy>
+#include <QNetworkReply>
+#include <QQmlNetworkAccessManagerFactory>
+#include <QQmlFileSelector>
+#ifndef QT_NO_SSL
+#include <QSslConfiguration>
+#include <QSslCertificate>
+#endif
 
 #include <string.h>
 
@@ -54,6 +75,75 @@ void newGuiApplication()
     qApp->setQuitOnLastWindowClosed(false);
 }
 
+void setApplicationInfo(const char *name, const char *org, const char *version)
+{
+    QCoreApplication::setApplicationName(QString::fromUtf8(name));
+    QCoreApplication::setOrganizationName(QString::fromUtf8(org));
+    QCoreApplication::setApplicationVersion(QString::fromUtf8(version));
+}
+
+const char *qtRuntimeVersion()
+{
+    return qVersion();
+}
+
+void setHighDpiScaling(int enabled)
+{
+#if QT_VERSION >= QT_VERSION_CHECK(5, 6, 0)
+    QCoreApplication::setAttribute(Qt::AA_EnableHighDpiScaling, enabled);
+    QCoreApplication::setAttribute(Qt::AA_DisableHighDpiScaling, !enabled);
+#endif
+}
+
+void setHighDpiScaleFactorRoundingPolicy(int policy)
+{
+#if QT_VERSION >= QT_VERSION_CHECK(5, 14, 0)
+    Qt::HighDpiScaleFactorRoundingPolicy qpolicy;
+    switch (policy) {
+    case 0:
+        qpolicy = Qt::HighDpiScaleFactorRoundingPolicy::Round;
+        break;
+    case 1:
+        qpolicy = Qt::HighDpiScaleFactorRoundingPolicy::Ceil;
+        break;
+    case 2:
+        qpolicy = Qt::HighDpiScaleFactorRoundingPolicy::Floor;
+        break;
+    case 3:
+        qpolicy = Qt::HighDpiScaleFactorRoundingPolicy::RoundPreferFloor;
+        break;
+    default:
+        qpolicy = Qt::HighDpiScaleFactorRoundingPolicy::PassThrough;
+        break;
+    }
+    QGuiApplication::setHighDpiScaleFactorRoundingPolicy(qpolicy);
+#endif
+}
+
+error *setSceneGraphBackend(int backend)
+{
+#if QT_VERSION >= QT_VERSION_CHECK(5, 8, 0)
+    switch (backend) {
+    case 1:
+        QQuickWindow::setSceneGraphBackend(QSGRendererInterface::OpenGL);
+        break;
+    case 2:
+        QQuickWindow::setSceneGraphBackend(QSGRendererInterface::Software);
+        break;
+    default:
+        // An empty name restores the default selection logic.
+        QQuickWindow::setSceneGraphBackend(QString());
+        break;
+    }
+    return 0;
+#else
+    if (backend == 0) {
+        return 0;
+    }
+    return errorf("choosing the graphics backend requires Qt 5.8 or later");
+#endif
+}
+
 void applicationExec()
 {
     qApp->exec();
@@ -81,7 +171,11 @@ void *appThread()
 
 QQmlEngine_ *newEngine(QObject_ *parent)
 {
-    return new QQmlEngine(reinterpret_cast<QObject *>(parent));
+    QQmlEngine *qengine = new QQmlEngine(reinterpret_cast<QObject *>(parent));
+    // Picks the "+lang" variants of files, such as those packed by genqrc
+    // from .qrc resources with a lang attribute. See engineSetUILanguage.
+    new QQmlFileSelector(qengine, qengine);
+    return qengine;
 }
 
 QQmlContext_ *engineRootContext(QQmlEngine_ *engine)
@@ -139,7 +233,14 @@ class GoImageProvider : public QQuickImageProvider {
             width = requestedSize.width();
             height = requestedSize.height();
         }
-        QImage *ptr = reinterpret_cast<QImage *>(hookRequestImage(imageFunc, (char*)ba.constData(), ba.size(), width, height));
+        char *err = 0;
+        QImage *ptr = reinterpret_cast<QImage *>(hookRequestImage(imageFunc, (char*)ba.constData(), ba.size(), width, height, &err));
+        if (!ptr) {
+            qWarning("cannot provide image %s: %s", ba.constData(), err);
+            free(err);
+            *size = QSize();
+            return QImage();
+        }
         QImage image = *ptr;
         delete ptr;
 
@@ -171,6 +272,228 @@ void engineAddImportPath(QQmlEngine_ *engine, QString_ *path)
     qengine->addImportPath(*qpath);
 }
 
+void engineAddPluginPath(QQmlEngine_ *engine, QString_ *path)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QString *qpath = reinterpret_cast<QString *>(path);
+
+    qengine->addPluginPath(*qpath);
+}
+
+void enginePluginPathList(QQmlEngine_ *engine, DataValue *result)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+
+    QVariant var(QVariant(qengine->pluginPathList()).toList());
+    packDataValue(&var, result);
+}
+
+void engineClearComponentCache(QQmlEngine_ *engine)
+{
+    reinterpret_cast<QQmlEngine *>(engine)->clearComponentCache();
+}
+
+void setPixmapCacheLimit(int kb)
+{
+    QPixmapCache::setCacheLimit(kb);
+}
+
+void clearPixmapCache(QQmlEngine_ *engine)
+{
+    QPixmapCache::clear();
+    reinterpret_cast<QQmlEngine *>(engine)->collectGarbage();
+}
+
+void engineSetBaseUrl(QQmlEngine_ *engine, const char *url, int urlLen)
+{
+    QByteArray qurl(url, urlLen);
+    QString qsurl = QString::fromUtf8(qurl);
+    reinterpret_cast<QQmlEngine *>(engine)->setBaseUrl(qsurl);
+}
+
+void engineSetOfflineStoragePath(QQmlEngine_ *engine, QString_ *path)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QString *qpath = reinterpret_cast<QString *>(path);
+
+    qengine->setOfflineStoragePath(*qpath);
+}
+
+class GoNetworkAccessManager : public QNetworkAccessManager
+{
+    public:
+
+    QList<QPair<QByteArray, QByteArray> > headers;
+#ifndef QT_NO_SSL
+    bool customSsl;
+    QSslConfiguration ssl;
+#endif
+
+    GoNetworkAccessManager(QObject *parent) : QNetworkAccessManager(parent) {};
+
+    protected:
+
+    QNetworkReply *createRequest(Operation op, const QNetworkRequest &originalReq, QIODevice *outgoingData)
+    {
+        QNetworkRequest req(originalReq);
+        for (int i = 0; i < headers.size(); i++) {
+            req.setRawHeader(headers[i].first, headers[i].second);
+        }
+#ifndef QT_NO_SSL
+        if (customSsl && req.url().scheme() == "https") {
+            req.setSslConfiguration(ssl);
+        }
+#endif
+        return QNetworkAccessManager::createRequest(op, req, outgoingData);
+    }
+};
+
+// GoNetworkAccessManagerFactory is called from the engine's loader threads,
+// so its settings are never changed after it is handed to the engine.
+class GoNetworkAccessManagerFactory : public QQmlNetworkAccessManagerFactory
+{
+    public:
+
+    QList<QPair<QByteArray, QByteArray> > headers;
+    bool hasProxy;
+    QNetworkProxy proxy;
+#ifndef QT_NO_SSL
+    bool customSsl;
+    QSslConfiguration ssl;
+#endif
+
+    QNetworkAccessManager *create(QObject *parent)
+    {
+        GoNetworkAccessManager *nam = new GoNetworkAccessManager(parent);
+        nam->headers = headers;
+#ifndef QT_NO_SSL
+        nam->customSsl = customSsl;
+        nam->ssl = ssl;
+#endif
+        if (hasProxy) {
+            nam->setProxy(proxy);
+        }
+        return nam;
+    }
+};
+
+// GoNetworkFactoryHolder releases the factory set on its parent engine.
+class GoNetworkFactoryHolder : public QObject
+{
+    public:
+
+    QQmlNetworkAccessManagerFactory *factory;
+
+    GoNetworkFactoryHolder(QObject *parent) : QObject(parent), factory(0) {};
+
+    ~GoNetworkFactoryHolder()
+    {
+        delete factory;
+    }
+};
+
+error *engineSetNetworkConfig(QQmlEngine_ *engine, NetworkConfig *config)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+
+    GoNetworkAccessManagerFactory *factory = new GoNetworkAccessManagerFactory();
+    for (int i = 0; i+1 < config->headersLen; i += 2) {
+        factory->headers.append(qMakePair(QByteArray(config->headers[i]), QByteArray(config->headers[i+1])));
+    }
+    factory->hasProxy = config->proxyHost != 0;
+    if (factory->hasProxy) {
+        factory->proxy.setType(config->proxySocks ? QNetworkProxy::Socks5Proxy : QNetworkProxy::HttpProxy);
+        factory->proxy.setHostName(QString::fromUtf8(config->proxyHost));
+        factory->proxy.setPort(config->proxyPort);
+        factory->proxy.setUser(QString::fromUtf8(config->proxyUser));
+        factory->proxy.setPassword(QString::fromUtf8(config->proxyPassword));
+    }
+#ifndef QT_NO_SSL
+    factory->customSsl = config->caCertsLen > 0 || config->insecure;
+    factory->ssl = QSslConfiguration::defaultConfiguration();
+    if (config->caCertsLen > 0) {
+        QList<QSslCertificate> certs = QSslCertificate::fromData(QByteArray(config->caCerts, config->caCertsLen), QSsl::Pem);
+        if (certs.isEmpty()) {
+            delete factory;
+            return errorf("no certificates found in CA bundle");
+        }
+        factory->ssl.setCaCertificates(factory->ssl.caCertificates() + certs);
+    }
+    if (config->insecure) {
+        factory->ssl.setPeerVerifyMode(QSslSocket::VerifyNone);
+    }
+#else
+    if (config->caCertsLen > 0 || config->insecure) {
+        delete factory;
+        return errorf("TLS settings require Qt with SSL support");
+    }
+#endif
+
+    // The engine does not take ownership of the factory.
+    GoNetworkFactoryHolder *holder = 0;
+    foreach (QObject *child, qengine->children()) {
+        holder = dynamic_cast<GoNetworkFactoryHolder *>(child);
+        if (holder) {
+            break;
+        }
+    }
+    if (!holder) {
+        holder = new GoNetworkFactoryHolder(qengine);
+    }
+    qengine->setNetworkAccessManagerFactory(factory);
+    delete holder->factory;
+    holder->factory = factory;
+    return 0;
+}
+
+error *engineLoadTranslation(QQmlEngine_ *engine, const char *path)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QString qpath = QString::fromUtf8(path);
+    if (qpath.startsWith("qrc:")) {
+        // QTranslator knows resources as ":/path" rather than "qrc:///path".
+        qpath = ":" + QUrl(qpath).path();
+    }
+    // The translator is uninstalled when destroyed with the engine.
+    QTranslator *translator = new QTranslator(qengine);
+    if (!translator->load(qpath)) {
+        delete translator;
+        return errorf("cannot load translation from %s", path);
+    }
+    QCoreApplication::installTranslator(translator);
+    qengine->retranslate();
+    return 0;
+}
+
+void engineSetUILanguage(QQmlEngine_ *engine, const char *locale)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    // This is process-wide, as documented in Engine.SetUILanguage.
+    QLocale::setDefault(QLocale(QString::fromUtf8(locale)));
+
+    // Prefer files under "+pt_BR" and then "+pt" directories for "pt_BR".
+    QQmlFileSelector *selector = QQmlFileSelector::get(qengine);
+    if (selector) {
+        QString name = QString::fromUtf8(locale);
+        QStringList selectors(name);
+        int sep = name.indexOf('_');
+        if (sep > 0) {
+            selectors << name.left(sep);
+        }
+        selector->setExtraSelectors(selectors);
+    }
+#if QT_VERSION >= QT_VERSION_CHECK(5, 15, 0)
+    qengine->setUiLanguage(QString::fromUtf8(locale));
+#endif
+    qengine->retranslate();
+}
+
+char *engineOfflineStoragePath(QQmlEngine_ *engine)
+{
+    QByteArray ba = reinterpret_cast<QQmlEngine *>(engine)->offlineStoragePath().toUtf8();
+    return local_strdup(ba.constData());
+}
+
 void componentLoadURL(QQmlComponent_ *component, const char *url, int urlLen)
 {
     QByteArray qurl(url, urlLen);
@@ -178,6 +501,19 @@ void componentLoadURL(QQmlComponent_ *component, const char *url, int urlLen)
     reinterpret_cast<QQmlComponent *>(component)->loadUrl(qsurl);
 }
 
+QQmlComponent_ *enginePreload(QQmlEngine_ *engine, const char *url, int urlLen)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QByteArray qurl(url, urlLen);
+
+    // The compiled type is held in the engine's cache, so the component
+    // itself is deleted once it is done loading.
+    QQmlComponent *qcomponent = new QQmlComponent(qengine, qengine);
+    QQmlEngine::setContextForObject(qcomponent, qengine->rootContext());
+    qcomponent->loadUrl(QUrl(QString::fromUtf8(qurl)), QQmlComponent::Asynchronous);
+    return qcomponent;
+}
+
 void componentSetData(QQmlComponent_ *component, const char *data, int dataLen, const char *url, int urlLen)
 {
     QByteArray qdata(data, dataLen);
@@ -186,17 +522,35 @@ void componentSetData(QQmlComponent_ *component, const char *data, int dataLen,
     reinterpret_cast<QQmlComponent *>(component)->setData(qdata, qsurl);
 }
 
-char *componentErrorString(QQmlComponent_ *component)
+int componentIsReady(QQmlComponent_ *component)
 {
-    QQmlComponent *qcomponent = reinterpret_cast<QQmlComponent *>(component);
-    if (qcomponent->isReady()) {
-        return NULL;
+    return reinterpret_cast<QQmlComponent *>(component)->isReady();
+}
+
+int componentStatus(QQmlComponent_ *component)
+{
+    return reinterpret_cast<QQmlComponent *>(component)->status();
+}
+
+QmlError *componentErrors(QQmlComponent_ *component, int *errorsLen)
+{
+    QList<QQmlError> errors = reinterpret_cast<QQmlComponent *>(component)->errors();
+    *errorsLen = errors.length();
+    if (errors.isEmpty()) {
+        return 0;
     }
-    if (qcomponent->isError()) {
-        QByteArray ba = qcomponent->errorString().toUtf8();
-        return local_strdup(ba.constData());
+    QmlError *result = (QmlError *)malloc(sizeof(QmlError) * errors.length());
+    for (int i = 0; i < errors.length(); i++) {
+        const QQmlError &error = errors.at(i);
+        QByteArray url = error.url().toString().toUtf8();
+        QByteArray description = error.description().toUtf8();
+        result[i].url = local_strdup(url.constData());
+        result[i].description = local_strdup(description.constData());
+        result[i].stack = 0;
+        result[i].line = error.line();
+        result[i].column = error.column();
     }
-    return local_strdup("component is not ready (why!?)");
+    return result;
 }
 
 QObject_ *componentCreate(QQmlComponent_ *component, QQmlContext_ *context)
@@ -210,6 +564,43 @@ QObject_ *componentCreate(QQmlComponent_ *component, QQmlContext_ *context)
     return qcomponent->create(qcontext);
 }
 
+QObject_ *componentCreateWithProperties(QQmlComponent_ *component, QQmlContext_ *context, DataValue *props, error **err)
+{
+    QQmlComponent *qcomponent = reinterpret_cast<QQmlComponent *>(component);
+    QQmlContext *qcontext = reinterpret_cast<QQmlContext *>(context);
+
+    if (!qcontext) {
+        qcontext = qmlContext(qcomponent);
+    }
+    QVariant var;
+    unpackDataValue(props, &var);
+    QVariantMap qprops = var.toMap();
+
+#if QT_VERSION >= QT_VERSION_CHECK(5, 14, 0)
+    return qcomponent->createWithInitialProperties(qprops, qcontext);
+#else
+    QObject *obj = qcomponent->beginCreate(qcontext);
+    if (!obj) {
+        return 0;
+    }
+    QString missing;
+    for (QVariantMap::const_iterator it = qprops.constBegin(); it != qprops.constEnd(); ++it) {
+        if (obj->metaObject()->indexOfProperty(it.key().toUtf8().constData()) == -1) {
+            missing = it.key();
+            break;
+        }
+        obj->setProperty(it.key().toUtf8().constData(), it.value());
+    }
+    qcomponent->completeCreate();
+    if (!missing.isEmpty()) {
+        delete obj;
+        *err = errorf("object does not have a \"%s\" property", missing.toUtf8().constData());
+        return 0;
+    }
+    return obj;
+#endif
+}
+
 QQuickWindow_ *componentCreateWindow(QQmlComponent_ *component, QQmlContext_ *context)
 {
     QQmlComponent *qcomponent = reinterpret_cast<QQmlComponent *>(component);
@@ -256,12 +647,100 @@ void windowConnectHidden(QQuickWindow_ *win)
 {
     QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
     QObject::connect(qwin, &QWindow::visibleChanged, [=](bool visible){
-        if (!visible) {
-            hookWindowHidden(win);
+        if (!visible && !qwin->property("_qml_remapping").toBool()) {
+            hookWindowHidden(win, 0);
+        }
+    });
+    QObject::connect(qwin, &QObject::destroyed, [=]() {
+        hookWindowHidden(win, 1);
+    });
+}
+
+class WindowCloseFilter : public QObject
+{
+    public:
+
+    WindowCloseFilter(QObject *parent) : QObject(parent) {};
+
+    bool eventFilter(QObject *watched, QEvent *event)
+    {
+        if (event->type() == QEvent::Close && !hookWindowClose(watched)) {
+            event->ignore();
+            return true;
+        }
+        return false;
+    }
+};
+
+void windowConnectClose(QQuickWindow_ *win)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    qwin->installEventFilter(new WindowCloseFilter(qwin));
+    QObject::connect(qwin, &QObject::destroyed, [=]() {
+        hookWindowCloseReleased(win);
+    });
+}
+
+class WindowFileDropFilter : public QObject
+{
+    public:
+
+    WindowFileDropFilter(QObject *parent) : QObject(parent) {};
+
+    bool eventFilter(QObject *watched, QEvent *event)
+    {
+        switch (event->type()) {
+        case QEvent::DragEnter:
+        case QEvent::DragMove:
+        case QEvent::Drop:
+            break;
+        default:
+            return false;
+        }
+        QDropEvent *drop = static_cast<QDropEvent *>(event);
+        QList<QByteArray> paths;
+        foreach (const QUrl &url, drop->mimeData()->urls()) {
+            if (url.isLocalFile()) {
+                paths.append(url.toLocalFile().toUtf8());
+            }
         }
+        if (paths.isEmpty()) {
+            return false;
+        }
+        drop->acceptProposedAction();
+        if (event->type() == QEvent::Drop) {
+            QVector<char *> cpaths;
+            for (int i = 0; i < paths.size(); i++) {
+                cpaths.append(paths[i].data());
+            }
+            hookWindowFileDrop(watched, cpaths.data(), cpaths.size(), drop->pos().x(), drop->pos().y());
+        }
+        return true;
+    }
+};
+
+void windowConnectFileDrop(QQuickWindow_ *win)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    WindowFileDropFilter *filter = new WindowFileDropFilter(qwin);
+    qwin->installEventFilter(filter);
+    // Disconnected together with the filter. See windowDisconnectFileDrop.
+    QObject::connect(qwin, &QObject::destroyed, filter, [=]() {
+        hookWindowFileDropReleased(win);
     });
 }
 
+void windowDisconnectFileDrop(QQuickWindow_ *win)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    foreach (QObject *child, qwin->children()) {
+        if (dynamic_cast<WindowFileDropFilter *>(child)) {
+            // Deleting the filter also uninstalls it.
+            delete child;
+        }
+    }
+}
+
 QObject_ *windowRootObject(QQuickWindow_ *win)
 {
     if (objectIsView(win)) {
@@ -270,6 +749,16 @@ QObject_ *windowRootObject(QQuickWindow_ *win)
     return win;
 }
 
+QObject_ *windowActiveFocusItem(QQuickWindow_ *win)
+{
+    return reinterpret_cast<QQuickWindow *>(win)->activeFocusItem();
+}
+
+void itemForceActiveFocus(QObject_ *item)
+{
+    reinterpret_cast<QQuickItem *>(item)->forceActiveFocus();
+}
+
 QImage_ *windowGrabWindow(QQuickWindow_ *win)
 {
     QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
@@ -278,6 +767,342 @@ QImage_ *windowGrabWindow(QQuickWindow_ *win)
     return image;
 }
 
+int windowIsExposed(QQuickWindow_ *win)
+{
+    return reinterpret_cast<QQuickWindow *>(win)->isExposed();
+}
+
+void windowSetIcon(QQuickWindow_ *win, QImage_ **images, int imagesLen)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    QIcon icon;
+    for (int i = 0; i < imagesLen; i++) {
+        icon.addPixmap(QPixmap::fromImage(*reinterpret_cast<QImage *>(images[i])));
+    }
+    qwin->setIcon(icon);
+}
+
+error *windowSetIconFile(QQuickWindow_ *win, const char *path)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    QString qpath = QString::fromUtf8(path);
+    if (qpath.startsWith("qrc:")) {
+        // QImage knows resources as ":/path" rather than "qrc:///path".
+        qpath = ":" + QUrl(qpath).path();
+    }
+    QImage image(qpath);
+    if (image.isNull()) {
+        return errorf("cannot load window icon from %s", path);
+    }
+    qwin->setIcon(QIcon(QPixmap::fromImage(image)));
+    return 0;
+}
+
+void windowSetFullscreen(QQuickWindow_ *win, int fullscreen)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    qwin->setWindowState(fullscreen ? Qt::WindowFullScreen : Qt::WindowNoState);
+}
+
+static void windowSetFlag(QQuickWindow *qwin, Qt::WindowType flag, bool on)
+{
+    Qt::WindowFlags flags = qwin->flags();
+    if (on) {
+        flags |= flag;
+    } else {
+        flags &= ~flag;
+    }
+    if (flags == qwin->flags()) {
+        return;
+    }
+    if (!qwin->isVisible()) {
+        qwin->setFlags(flags);
+        return;
+    }
+    // Some platforms only honor flag changes when the window is mapped again.
+    // The window isn't really going away, so Wait must not return meanwhile.
+    qwin->setProperty("_qml_remapping", true);
+    qwin->hide();
+    qwin->setFlags(flags);
+    reinterpret_cast<DoShowWindow *>(qwin)->show();
+    qwin->setProperty("_qml_remapping", QVariant());
+}
+
+void windowSetFrameless(QQuickWindow_ *win, int frameless)
+{
+    windowSetFlag(reinterpret_cast<QQuickWindow *>(win), Qt::FramelessWindowHint, frameless);
+}
+
+void windowSetAlwaysOnTop(QQuickWindow_ *win, int onTop)
+{
+    windowSetFlag(reinterpret_cast<QQuickWindow *>(win), Qt::WindowStaysOnTopHint, onTop);
+}
+
+void windowSetCursorVisible(QQuickWindow_ *win, int visible)
+{
+    QQuickWindow *qwin = reinterpret_cast<QQuickWindow *>(win);
+    if (visible) {
+        qwin->unsetCursor();
+    } else {
+        qwin->setCursor(Qt::BlankCursor);
+    }
+}
+
+void windowGeometry(QQuickWindow_ *win, int *x, int *y, int *width, int *height)
+{
+    QRect rect = reinterpret_cast<QQuickWindow *>(win)->geometry();
+    *x = rect.x();
+    *y = rect.y();
+    *width = rect.width();
+    *height = rect.height();
+}
+
+void windowSetPosition(QQuickWindow_ *win, int x, int y)
+{
+    reinterpret_cast<QQuickWindow *>(win)->setPosition(x, y);
+}
+
+void windowSetGeometry(QQuickWindow_ *win, int x, int y, int width, int height)
+{
+    reinterpret_cast<QQuickWindow *>(win)->setGeometry(x, y, width, height);
+}
+
+QScreen_ *windowScreen(QQuickWindow_ *win)
+{
+    return reinterpret_cast<QQuickWindow *>(win)->screen();
+}
+
+double windowDevicePixelRatio(QQuickWindow_ *win)
+{
+    return reinterpret_cast<QQuickWindow *>(win)->devicePixelRatio();
+}
+
+void settingsValue(const char *org, const char *app, const char *key, DataValue *def, DataValue *result)
+{
+    QSettings settings(QString::fromUtf8(org), QString::fromUtf8(app));
+    QVariant qdef;
+    unpackDataValue(def, &qdef);
+    QVariant value = settings.value(QString::fromUtf8(key), qdef);
+    if (value.userType() == QMetaType::QStringList) {
+        value = value.toList();
+    }
+    // Some formats store everything as strings, so convert values
+    // back to the type of the default value when possible.
+    if (qdef.isValid() && value.userType() != qdef.userType()) {
+        if (qdef.userType() == QMetaType::QVariantList && value.userType() == QMetaType::QString) {
+            value = QVariantList() << value;
+        } else if (!value.convert(qdef.userType())) {
+            value = qdef;
+        }
+    }
+    packDataValue(&value, result);
+}
+
+void settingsSetValue(const char *org, const char *app, const char *key, DataValue *value)
+{
+    QSettings settings(QString::fromUtf8(org), QString::fromUtf8(app));
+    QVariant qvalue;
+    unpackDataValue(value, &qvalue);
+    settings.setValue(QString::fromUtf8(key), qvalue);
+}
+
+void settingsRemove(const char *org, const char *app, const char *key)
+{
+    QSettings settings(QString::fromUtf8(org), QString::fromUtf8(app));
+    settings.remove(QString::fromUtf8(key));
+}
+
+error *settingsSync(const char *org, const char *app)
+{
+    QSettings settings(QString::fromUtf8(org), QString::fromUtf8(app));
+    settings.sync();
+    switch (settings.status()) {
+    case QSettings::AccessError:
+        return errorf("cannot sync settings to %s: access error", settings.fileName().toUtf8().constData());
+    case QSettings::FormatError:
+        return errorf("cannot sync settings from %s: format error", settings.fileName().toUtf8().constData());
+    default:
+        return 0;
+    }
+}
+
+char *clipboardText()
+{
+    QByteArray ba = QGuiApplication::clipboard()->text().toUtf8();
+    return local_strdup(ba.constData());
+}
+
+void clipboardSetText(const char *text, int textLen)
+{
+    QGuiApplication::clipboard()->setText(QString::fromUtf8(text, textLen));
+}
+
+void clipboardConnectChanged()
+{
+    QObject::connect(QGuiApplication::clipboard(), &QClipboard::dataChanged, []() {
+        hookClipboardChanged();
+    });
+}
+
+error *addApplicationFont(const char *data, int dataLen, const char *path, char **families)
+{
+    int id;
+    if (path) {
+        QString qpath = QString::fromUtf8(path);
+        if (qpath.startsWith("qrc:")) {
+            // QFontDatabase knows resources as ":/path" rather than "qrc:///path".
+            qpath = ":" + QUrl(qpath).path();
+        }
+        id = QFontDatabase::addApplicationFont(qpath);
+        if (id == -1) {
+            return errorf("cannot add application font from %s", path);
+        }
+    } else {
+        id = QFontDatabase::addApplicationFontFromData(QByteArray(data, dataLen));
+        if (id == -1) {
+            return errorf("cannot add application font: unsupported font data");
+        }
+    }
+    QStringList qfamilies = QFontDatabase::applicationFontFamilies(id);
+    if (qfamilies.isEmpty()) {
+        QFontDatabase::removeApplicationFont(id);
+        return errorf("cannot add application font: font has no families");
+    }
+    QByteArray ba = qfamilies.join("\n").toUtf8();
+    *families = local_strdup(ba.constData());
+    return 0;
+}
+
+void applicationSetOverrideCursor(int shape)
+{
+    QGuiApplication::setOverrideCursor(QCursor(Qt::CursorShape(shape)));
+}
+
+void applicationRestoreOverrideCursor()
+{
+    QGuiApplication::restoreOverrideCursor();
+}
+
+QScreen_ **applicationScreens(int *screensLen)
+{
+    QList<QScreen *> screens = QGuiApplication::screens();
+    *screensLen = screens.length();
+    QScreen_ **result = (QScreen_ **)malloc(sizeof(QScreen_ *) * (screens.length() + 1));
+    for (int i = 0; i < screens.length(); i++) {
+        result[i] = screens.at(i);
+    }
+    return result;
+}
+
+void applicationConnectScreensChanged()
+{
+    QObject::connect(qApp, &QGuiApplication::screenAdded, [](QScreen *) {
+        hookScreensChanged();
+    });
+    QObject::connect(qApp, &QGuiApplication::screenRemoved, [](QScreen *) {
+        hookScreensChanged();
+    });
+    QObject::connect(qApp, &QGuiApplication::primaryScreenChanged, [](QScreen *) {
+        hookScreensChanged();
+    });
+}
+
+// liveScreen returns the screen at the provided address, or null if
+// the screen has been disconnected in the meantime.
+static QScreen *liveScreen(QScreen_ *screen)
+{
+    QScreen *qscreen = reinterpret_cast<QScreen *>(screen);
+    if (!QGuiApplication::screens().contains(qscreen)) {
+        return 0;
+    }
+    return qscreen;
+}
+
+char *screenName(QScreen_ *screen)
+{
+    QScreen *qscreen = liveScreen(screen);
+    if (!qscreen) {
+        return local_strdup("");
+    }
+    QByteArray ba = qscreen->name().toUtf8();
+    return local_strdup(ba.constData());
+}
+
+void screenGeometry(QScreen_ *screen, int *x, int *y, int *width, int *height)
+{
+    QScreen *qscreen = liveScreen(screen);
+    QRect rect;
+    if (qscreen) {
+        rect = qscreen->geometry();
+    }
+    *x = rect.x();
+    *y = rect.y();
+    *width = rect.width();
+    *height = rect.height();
+}
+
+void screenAvailableGeometry(QScreen_ *screen, int *x, int *y, int *width, int *height)
+{
+    QScreen *qscreen = liveScreen(screen);
+    QRect rect;
+    if (qscreen) {
+        rect = qscreen->availableGeometry();
+    }
+    *x = rect.x();
+    *y = rect.y();
+    *width = rect.width();
+    *height = rect.height();
+}
+
+double screenDevicePixelRatio(QScreen_ *screen)
+{
+    QScreen *qscreen = liveScreen(screen);
+    if (!qscreen) {
+        return 0;
+    }
+    return qscreen->devicePixelRatio();
+}
+
+void painterSetRenderHint(QPainter_ *painter, int hint, int on)
+{
+    reinterpret_cast<QPainter *>(painter)->setRenderHint(QPainter::RenderHint(hint), on);
+}
+
+int painterRenderHints(QPainter_ *painter)
+{
+    return reinterpret_cast<QPainter *>(painter)->renderHints();
+}
+
+void painterSetFont(QPainter_ *painter, const char *family, int familyLen, double pointSize)
+{
+    QPainter *qpainter = reinterpret_cast<QPainter *>(painter);
+    QFont font = qpainter->font();
+    font.setFamily(QString::fromUtf8(family, familyLen));
+    font.setPointSizeF(pointSize);
+    qpainter->setFont(font);
+}
+
+void painterTextExtents(QPainter_ *painter, const char *text, int textLen, double *width, double *height)
+{
+    QFontMetricsF metrics(reinterpret_cast<QPainter *>(painter)->font());
+    QSizeF size = metrics.size(0, QString::fromUtf8(text, textLen));
+    *width = size.width();
+    *height = size.height();
+}
+
+void painterDrawText(QPainter_ *painter, double x, double y, const char *text, int textLen)
+{
+    QPainter *qpainter = reinterpret_cast<QPainter *>(painter);
+    QString qtext = QString::fromUtf8(text, textLen);
+    QSizeF size = QFontMetricsF(qpainter->font()).size(0, qtext);
+
+    // Painting happens in native mode so Go code may use OpenGL directly,
+    // but QPainter operations are not available in that mode.
+    qpainter->endNativePainting();
+    qpainter->drawText(QRectF(QPointF(x, y), size), Qt::AlignLeft | Qt::AlignTop, qtext);
+    qpainter->beginNativePainting();
+}
+
 QImage_ *newImage(int width, int height)
 {
     return new QImage(width, height, QImage::Format_ARGB32_Premultiplied);
@@ -333,8 +1158,35 @@ void contextSetProperty(QQmlContext_ *context, QString_ *name, DataValue *value)
     if (obj && !qmlEngine(obj)) {
         QQmlEngine::setContextForObject(obj, qcontext);
     }
-
-    qcontext->setContextProperty(*qname, var);
+
+    qcontext->setContextProperty(*qname, var);
+}
+
+error *contextSetFunc(QQmlContext_ *context, QString_ *name, QObject_ *value)
+{
+    QQmlContext *qcontext = reinterpret_cast<QQmlContext *>(context);
+    const QString *qname = reinterpret_cast<QString *>(name);
+    QObject *qvalue = reinterpret_cast<QObject *>(value);
+    QQmlEngine *qengine = qcontext->engine();
+
+    // The Go side returns [result, error message], and the error
+    // is turned into an exception so it may be caught in QML.
+    QJSValue wrap = qengine->evaluate(
+        "(function(f) {"
+        "    return function() {"
+        "        var r = f.invoke(Array.prototype.slice.call(arguments));"
+        "        if (r[1] !== \"\") {"
+        "            throw new Error(r[1]);"
+        "        }"
+        "        return r[0];"
+        "    };"
+        "})");
+    QJSValue func = wrap.call(QJSValueList() << qengine->newQObject(qvalue));
+    if (func.isError()) {
+        return errorf("cannot set function %s: %s", qname->toUtf8().constData(), func.toString().toUtf8().constData());
+    }
+    qcontext->setContextProperty(*qname, QVariant::fromValue(func));
+    return 0;
 }
 
 void contextGetProperty(QQmlContext_ *context, QString_ *name, DataValue *result)
@@ -349,7 +1201,11 @@ void contextGetProperty(QQmlContext_ *context, QString_ *name, DataValue *result
 QQmlContext_ *contextSpawn(QQmlContext_ *context)
 {
     QQmlContext *qcontext = reinterpret_cast<QQmlContext *>(context);
-    return new QQmlContext(qcontext);
+    QQmlContext *qspawned = new QQmlContext(qcontext);
+    QObject::connect(qspawned, &QObject::destroyed, [=]() {
+        hookContextDestroyed(qspawned);
+    });
+    return qspawned;
 }
 
 void delObject(QObject_ *object)
@@ -362,11 +1218,88 @@ void delObjectLater(QObject_ *object)
     reinterpret_cast<QObject *>(object)->deleteLater();
 }
 
+void objectConnectDestroyed(QObject_ *object)
+{
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+    QObject::connect(qobject, &QObject::destroyed, [=]() {
+        hookObjectDestroyed(object);
+    });
+}
+
 const char *objectTypeName(QObject_ *object)
 {
     return reinterpret_cast<QObject *>(object)->metaObject()->className();
 }
 
+QMetaObject_ *objectMetaObject(QObject_ *object)
+{
+    return const_cast<QMetaObject *>(reinterpret_cast<QObject *>(object)->metaObject());
+}
+
+QObject_ *newPlainDateTime(int64_t msecs, int offset)
+{
+    QDateTime *dt = new QDateTime(QDateTime::fromMSecsSinceEpoch(msecs, Qt::OffsetFromUTC, offset));
+    return new PlainObject("QDateTime", dt);
+}
+
+void delPlainDateTime(QObject_ *plain)
+{
+    PlainObject *qplain = reinterpret_cast<PlainObject *>(plain);
+    delete reinterpret_cast<QDateTime *>(qplain->getPlainAddr());
+    delete qplain;
+}
+
+int64_t plainDateTimeMSecs(QObject_ *plain)
+{
+    PlainObject *qplain = reinterpret_cast<PlainObject *>(plain);
+    return reinterpret_cast<QDateTime *>(qplain->getPlainAddr())->toMSecsSinceEpoch();
+}
+
+MetaPropertyInfo *objectMetaProperties(QObject_ *object, int *propertiesLen)
+{
+    const QMetaObject *meta = reinterpret_cast<QObject *>(object)->metaObject();
+    int count = meta->propertyCount();
+    *propertiesLen = count;
+    MetaPropertyInfo *result = (MetaPropertyInfo *)malloc(sizeof(MetaPropertyInfo) * (count + 1));
+    for (int i = 0; i < count; i++) {
+        QMetaProperty property = meta->property(i);
+        result[i].name = local_strdup(property.name());
+        result[i].typeName = local_strdup(property.typeName());
+        result[i].writable = property.isWritable();
+        result[i].notifies = property.hasNotifySignal();
+    }
+    return result;
+}
+
+MetaMethodInfo *objectMetaMethods(QObject_ *object, int *methodsLen)
+{
+    const QMetaObject *meta = reinterpret_cast<QObject *>(object)->metaObject();
+    int count = meta->methodCount();
+    MetaMethodInfo *result = (MetaMethodInfo *)malloc(sizeof(MetaMethodInfo) * (count + 1));
+    int len = 0;
+    for (int i = 0; i < count; i++) {
+        QMetaMethod method = meta->method(i);
+        if (method.methodType() == QMetaMethod::Constructor || method.access() == QMetaMethod::Private) {
+            continue;
+        }
+        MetaMethodInfo *info = &result[len++];
+        QList<QByteArray> types = method.parameterTypes();
+        QList<QByteArray> names = method.parameterNames();
+        info->name = local_strdup(method.name().constData());
+        info->returnType = local_strdup(method.typeName());
+        info->paramsLen = types.size();
+        info->paramTypes = (char **)malloc(sizeof(char *) * (types.size() + 1));
+        info->paramNames = (char **)malloc(sizeof(char *) * (types.size() + 1));
+        for (int j = 0; j < types.size(); j++) {
+            info->paramTypes[j] = local_strdup(types[j].constData());
+            info->paramNames[j] = local_strdup(j < names.size() ? names[j].constData() : "");
+        }
+        info->methodType = method.methodType();
+    }
+    *methodsLen = len;
+    return result;
+}
+
 int objectGetProperty(QObject_ *object, const char *name, DataValue *result)
 {
     QObject *qobject = reinterpret_cast<QObject *>(object);
@@ -430,6 +1363,31 @@ error *objectSetProperty(QObject_ *object, const char *name, DataValue *value)
     return 0;
 }
 
+// objectFindMethod looks for the method or slot of qobject with the given
+// name, and returns an error if there is none or it cannot take paramsLen
+// arguments. Descendants have priority over the types they derive from.
+static error *objectFindMethod(QObject *qobject, const char *method, int methodLen, int paramsLen, QMetaMethod *result)
+{
+    const QMetaObject *metaObject = qobject->metaObject();
+    // Walk backwards so descendants have priority.
+    for (int i = metaObject->methodCount()-1; i >= 0; i--) {
+        QMetaMethod metaMethod = metaObject->method(i);
+        QMetaMethod::MethodType methodType = metaMethod.methodType();
+        if (methodType == QMetaMethod::Method || methodType == QMetaMethod::Slot) {
+            QByteArray name = metaMethod.name();
+            if (name.length() == methodLen && qstrncmp(name.constData(), method, methodLen) == 0) {
+                if (metaMethod.parameterCount() < paramsLen) {
+                    // TODO Might continue looking to see if a different signal has the same name and enough arguments.
+                    return errorf("method \"%s\" has too few parameters for provided arguments", method);
+                }
+                *result = metaMethod;
+                return 0;
+            }
+        }
+    }
+    return errorf("object does not expose a method \"%s\"", method);
+}
+
 error *objectInvoke(QObject_ *object, const char *method, int methodLen, DataValue *resultdv, DataValue *paramsdv, int paramsLen)
 {
     QObject *qobject = reinterpret_cast<QObject *>(object);
@@ -445,38 +1403,111 @@ error *objectInvoke(QObject_ *object, const char *method, int methodLen, DataVal
         panicf("fix the parameter dispatching");
     }
 
-    const QMetaObject *metaObject = qobject->metaObject();
-    // Walk backwards so descendants have priority.
-    for (int i = metaObject->methodCount()-1; i >= 0; i--) {
-        QMetaMethod metaMethod = metaObject->method(i);
-        QMetaMethod::MethodType methodType = metaMethod.methodType();
-        if (methodType == QMetaMethod::Method || methodType == QMetaMethod::Slot) {
-            QByteArray name = metaMethod.name();
-            if (name.length() == methodLen && qstrncmp(name.constData(), method, methodLen) == 0) {
-                if (metaMethod.parameterCount() < paramsLen) {
-                    // TODO Might continue looking to see if a different signal has the same name and enough arguments.
-                    return errorf("method \"%s\" has too few parameters for provided arguments", method);
-                }
+    QMetaMethod metaMethod;
+    error *err = objectFindMethod(qobject, method, methodLen, paramsLen, &metaMethod);
+    if (err) {
+        return err;
+    }
 
-                bool ok;
-                if (metaMethod.returnType() == QMetaType::Void) {
-                    ok = metaMethod.invoke(qobject, Qt::DirectConnection, 
-                        arg[0], arg[1], arg[2], arg[3], arg[4], arg[5], arg[6], arg[7], arg[8], arg[9]);
-                } else {
-                    ok = metaMethod.invoke(qobject, Qt::DirectConnection, Q_RETURN_ARG(QVariant, result),
-                        arg[0], arg[1], arg[2], arg[3], arg[4], arg[5], arg[6], arg[7], arg[8], arg[9]);
-                }
-                if (!ok) {
-                    return errorf("invalid parameters to method \"%s\"", method);
-                }
+    bool ok;
+    if (metaMethod.returnType() == QMetaType::Void) {
+        ok = metaMethod.invoke(qobject, Qt::DirectConnection, 
+            arg[0], arg[1], arg[2], arg[3], arg[4], arg[5], arg[6], arg[7], arg[8], arg[9]);
+    } else {
+        ok = metaMethod.invoke(qobject, Qt::DirectConnection, Q_RETURN_ARG(QVariant, result),
+            arg[0], arg[1], arg[2], arg[3], arg[4], arg[5], arg[6], arg[7], arg[8], arg[9]);
+    }
+    if (!ok) {
+        return errorf("invalid parameters to method \"%s\"", method);
+    }
 
-                packDataValue(&result, resultdv);
-                return 0;
-            }
-        }
+    packDataValue(&result, resultdv);
+    return 0;
+}
+
+error *objectInvokeCatch(QQmlEngine_ *engine, QObject_ *object, const char *method, int methodLen, DataValue *resultdv, DataValue *paramsdv, int paramsLen, QmlError *exception)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+
+    QMetaMethod metaMethod;
+    error *err = objectFindMethod(qobject, method, methodLen, paramsLen, &metaMethod);
+    if (err) {
+        return err;
     }
 
-    return errorf("object does not expose a method \"%s\"", method);
+    QJSValue args = qengine->newArray(paramsLen);
+    for (int i = 0; i < paramsLen; i++) {
+        QVariant param;
+        unpackDataValue(&paramsdv[i], &param);
+        args.setProperty(i, qengine->toScriptValue(param));
+    }
+
+    // The method is called from JavaScript so that thrown values are caught
+    // as such. Warnings the engine reports while the method runs, such as
+    // those of bindings that break due to its side effects, are not errors.
+    // The calling function is compiled once and kept by the engine.
+    QVariant cached = qengine->property("_qml_invokeCatch");
+    QJSValue call;
+    if (cached.isValid()) {
+        call = cached.value<QJSValue>();
+    } else {
+        call = qengine->evaluate(
+            "(function(obj, name, args) {"
+            "    try {"
+            "        return {value: obj[name].apply(obj, args)};"
+            "    } catch (e) {"
+            "        return {thrown: true, exception: e};"
+            "    }"
+            "})");
+        qengine->setProperty("_qml_invokeCatch", QVariant::fromValue(call));
+    }
+    QJSValue outcome = call.call(QJSValueList() << qengine->toScriptValue(qobject) << QString::fromUtf8(method, methodLen) << args);
+    if (outcome.isError()) {
+        return errorf("cannot call method \"%s\": %s", method, outcome.toString().toUtf8().constData());
+    }
+    if (outcome.property("thrown").toBool()) {
+        QJSValue thrown = outcome.property("exception");
+        QJSValue fileName = thrown.property("fileName");
+        QJSValue lineNumber = thrown.property("lineNumber");
+        QJSValue stack = thrown.property("stack");
+        QByteArray url = fileName.isUndefined() ? QByteArray() : fileName.toString().toUtf8();
+        QByteArray description = thrown.toString().toUtf8();
+        exception->url = local_strdup(url.constData());
+        exception->description = local_strdup(description.constData());
+        exception->stack = stack.isString() ? local_strdup(stack.toString().toUtf8().constData()) : 0;
+        exception->line = lineNumber.isNumber() ? lineNumber.toInt() : -1;
+        exception->column = -1;
+        return 0;
+    }
+
+    QVariant result = outcome.property("value").toVariant();
+    packDataValue(&result, resultdv);
+    return 0;
+}
+
+error *objectEval(QQmlEngine_ *engine, QObject_ *object, const char *expr, int exprLen, DataValue *resultdv)
+{
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+
+    // Objects not created by QML have no context of their own.
+    QQmlContext *qcontext = QQmlEngine::contextForObject(qobject);
+    if (!qcontext) {
+        qcontext = qengine->rootContext();
+    }
+
+    QQmlExpression qexpr(qcontext, qobject, QString::fromUtf8(expr, exprLen));
+    QVariant var = qexpr.evaluate();
+    if (qexpr.hasError()) {
+        QByteArray ba = qexpr.error().toString().toUtf8();
+        return errorf("%s", ba.constData());
+    }
+    if (var.userType() == qMetaTypeId<QJSValue>()) {
+        var = var.value<QJSValue>().toVariant();
+    }
+    packDataValue(&var, resultdv);
+    return 0;
 }
 
 void objectFindChild(QObject_ *object, QString_ *name, DataValue *resultdv)
@@ -492,6 +1523,18 @@ void objectFindChild(QObject_ *object, QString_ *name, DataValue *resultdv)
     packDataValue(&var, resultdv);
 }
 
+void objectChildren(QObject_ *object, DataValue *resultdv)
+{
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+
+    QVariantList list;
+    for (QObject *child : qobject->children()) {
+        list.append(QVariant::fromValue(child));
+    }
+    QVariant var(list);
+    packDataValue(&var, resultdv);
+}
+
 void objectSetParent(QObject_ *object, QObject_ *parent)
 {
     QObject *qobject = reinterpret_cast<QObject *>(object);
@@ -500,7 +1543,7 @@ void objectSetParent(QObject_ *object, QObject_ *parent)
     qobject->setParent(qparent);
 }
 
-error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEngine_ *engine, void *func, int argsLen)
+error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEngine_ *engine, void *func, int argsLen, QObject_ **connector)
 {
     QObject *qobject = reinterpret_cast<QObject *>(object);
     QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
@@ -513,13 +1556,21 @@ error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEn
             if (method.methodType() == QMetaMethod::Signal) {
                 QByteArray name = method.name();
                 if (name.length() == signalLen && qstrncmp(name.constData(), signal, signalLen) == 0) {
+                    if (argsLen < 0) {
+                        // The function takes all parameters the signal carries.
+                        argsLen = method.parameterCount();
+                        if (argsLen > MaxParams) {
+                            return errorf("signal \"%s\" has too many parameters", name.constData());
+                        }
+                    }
                     if (method.parameterCount() < argsLen) {
                         // TODO Might continue looking to see if a different signal has the same name and enough arguments.
                         return errorf("signal \"%s\" has too few parameters for provided function", name.constData());
                     }
-                    Connector *connector = new Connector(qobject, method, qengine, func, argsLen);
-                    const QMetaObject *connmeta = connector->metaObject();
-                    QObject::connect(qobject, method, connector, connmeta->method(connmeta->methodOffset()));
+                    Connector *qconnector = new Connector(qobject, method, qengine, func, argsLen);
+                    const QMetaObject *connmeta = qconnector->metaObject();
+                    QObject::connect(qobject, method, qconnector, connmeta->method(connmeta->methodOffset()));
+                    *connector = qconnector;
                     return 0;
                 }
             }
@@ -528,6 +1579,85 @@ error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEn
     return errorf("object does not expose a \"%s\" signal", qsignal.data());
 }
 
+error *objectConnectNotify(QObject_ *object, const char *property, QQmlEngine_ *engine, void *func, QObject_ **connector)
+{
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+    QQmlEngine *qengine = reinterpret_cast<QQmlEngine *>(engine);
+
+    const QMetaObject *meta = qobject->metaObject();
+    int index = meta->indexOfProperty(property);
+    if (index == -1) {
+        return errorf("object does not have a \"%s\" property", property);
+    }
+    QMetaProperty metaProperty = meta->property(index);
+    if (!metaProperty.hasNotifySignal()) {
+        return errorf("property \"%s\" does not notify changes", property);
+    }
+    QMetaMethod method = metaProperty.notifySignal();
+    Connector *qconnector = new Connector(qobject, method, qengine, func, 0);
+    const QMetaObject *connmeta = qconnector->metaObject();
+    QObject::connect(qobject, method, qconnector, connmeta->method(connmeta->methodOffset()));
+    *connector = qconnector;
+    return 0;
+}
+
+error *objectEmit(QObject_ *object, const char *signal, int signalLen, DataValue *paramsdv, int paramsLen)
+{
+    QObject *qobject = reinterpret_cast<QObject *>(object);
+    QByteArray qsignal(signal, signalLen);
+
+    bool found = false;
+    const QMetaObject *meta = qobject->metaObject();
+    // Walk backwards so descendants have priority.
+    for (int i = meta->methodCount()-1; i >= 0; i--) {
+        QMetaMethod method = meta->method(i);
+        if (method.methodType() != QMetaMethod::Signal || method.name() != qsignal) {
+            continue;
+        }
+        found = true;
+        if (method.parameterCount() != paramsLen) {
+            continue;
+        }
+
+        // Convert the arguments to the declared parameter types.
+        QVariant param[MaxParams];
+        void *a[MaxParams+1];
+        a[0] = 0;
+        for (int j = 0; j < paramsLen; j++) {
+            unpackDataValue(&paramsdv[j], &param[j]);
+            int paramType = method.parameterType(j);
+            if (paramType == QMetaType::QVariant) {
+                a[j+1] = &param[j];
+                continue;
+            }
+            QByteArray typeName(param[j].typeName());
+            if (!param[j].convert(paramType)) {
+                return errorf("cannot use %s as parameter %d of signal \"%s\" (%s)",
+                    typeName.isEmpty() ? "nil" : typeName.constData(), j+1, qsignal.constData(), QMetaType::typeName(paramType));
+            }
+            a[j+1] = param[j].data();
+        }
+        QMetaObject::metacall(qobject, QMetaObject::InvokeMetaMethod, i, a);
+        return 0;
+    }
+    if (found) {
+        return errorf("signal \"%s\" does not take %d parameters", qsignal.constData(), paramsLen);
+    }
+    return errorf("object does not expose a \"%s\" signal", qsignal.constData());
+}
+
+void connectorDisconnect(QObject_ *connector)
+{
+    QObject *qconnector = reinterpret_cast<QObject *>(connector);
+
+    // Disconnect right away so that further emissions are not delivered,
+    // but delay the deletion as the connector may be running the handler
+    // that asked to disconnect. Destroying the connector releases the
+    // Go function via hookSignalDisconnect.
+    QObject::disconnect(qconnector->parent(), 0, qconnector, 0);
+    qconnector->deleteLater();
+}
+
 QQmlContext_ *objectContext(QObject_ *object)
 {
     return qmlContext(static_cast<QObject *>(object));
@@ -551,6 +1681,12 @@ int objectIsView(QObject_ *object)
     return dynamic_cast<QQuickView *>(qobject) ? 1 : 0;
 }
 
+int objectIsItem(QObject_ *object)
+{
+    QObject *qobject = static_cast<QObject *>(object);
+    return dynamic_cast<QQuickItem *>(qobject) ? 1 : 0;
+}
+
 error *objectGoAddr(QObject_ *object, GoAddr **addr)
 {
     QObject *qobject = static_cast<QObject *>(object);
@@ -637,10 +1773,42 @@ void unpackDataValue(DataValue *value, QVariant_ *var)
     case DTColor:
         *qvar = QColor::fromRgba(*(QRgb*)(value->data));
         break;
+    case DTBytes:
+        *qvar = QByteArray(*(char **)value->data, value->len);
+        break;
+    case DTDateTime:
+        // The length holds the offset from UTC in seconds.
+        *qvar = QDateTime::fromMSecsSinceEpoch(*(qint64*)(value->data), Qt::OffsetFromUTC, value->len);
+        break;
+    case DTPoint:
+        {
+            double *coords = *(double**)(value->data);
+            *qvar = QPointF(coords[0], coords[1]);
+            free(coords);
+        }
+        break;
+    case DTSize:
+        {
+            double *coords = *(double**)(value->data);
+            *qvar = QSizeF(coords[0], coords[1]);
+            free(coords);
+        }
+        break;
+    case DTRect:
+        {
+            double *coords = *(double**)(value->data);
+            *qvar = QRectF(coords[0], coords[1], coords[2], coords[3]);
+            free(coords);
+        }
+        break;
     case DTVariantList:
         *qvar = **(QVariantList**)(value->data);
         delete *(QVariantList**)(value->data);
         break;
+    case DTVariantMap:
+        *qvar = **(QVariantMap**)(value->data);
+        delete *(QVariantMap**)(value->data);
+        break;
     case DTObject:
         qvar->setValue(*(QObject**)(value->data));
         break;
@@ -717,6 +1885,59 @@ void packDataValue(QVariant_ *var, DataValue *value)
         value->dataType = DTColor;
         *(unsigned int*)(value->data) = qvar->value<QColor>().rgba();
         break;
+    case QMetaType::QPoint:
+    case QMetaType::QPointF:
+        {
+            QPointF point = qvar->toPointF();
+            double *coords = (double *) malloc(sizeof(double) * 2);
+            coords[0] = point.x();
+            coords[1] = point.y();
+            value->dataType = DTPoint;
+            *(double**)(value->data) = coords;
+        }
+        break;
+    case QMetaType::QSize:
+    case QMetaType::QSizeF:
+        {
+            QSizeF size = qvar->toSizeF();
+            double *coords = (double *) malloc(sizeof(double) * 2);
+            coords[0] = size.width();
+            coords[1] = size.height();
+            value->dataType = DTSize;
+            *(double**)(value->data) = coords;
+        }
+        break;
+    case QMetaType::QRect:
+    case QMetaType::QRectF:
+        {
+            QRectF rect = qvar->toRectF();
+            double *coords = (double *) malloc(sizeof(double) * 4);
+            coords[0] = rect.x();
+            coords[1] = rect.y();
+            coords[2] = rect.width();
+            coords[3] = rect.height();
+            value->dataType = DTRect;
+            *(double**)(value->data) = coords;
+        }
+        break;
+    case QMetaType::QByteArray:
+        {
+            QByteArray ba = qvar->toByteArray();
+            char *data = (char *)malloc(ba.size());
+            memcpy(data, ba.constData(), ba.size());
+            value->dataType = DTBytes;
+            *(char**)(value->data) = data;
+            value->len = ba.size();
+            break;
+        }
+    case QMetaType::QDateTime:
+        {
+            QDateTime dt = qvar->toDateTime();
+            value->dataType = DTDateTime;
+            *(qint64*)(value->data) = dt.toMSecsSinceEpoch();
+            value->len = dt.offsetFromUtc();
+            break;
+        }
     case QMetaType::QVariantList:
         {
             QVariantList varlist = qvar->toList();
@@ -807,6 +2028,16 @@ void packDataValue(QVariant_ *var, DataValue *value)
     }
 }
 
+int colorFromName(const char *name, unsigned int *rgba)
+{
+    QColor color(QString::fromUtf8(name));
+    if (!color.isValid()) {
+        return 0;
+    }
+    *rgba = color.rgba();
+    return 1;
+}
+
 QVariantList_ *newVariantList(DataValue *list, int len)
 {
     QVariantList *vlist = new QVariantList();
@@ -819,6 +2050,19 @@ QVariantList_ *newVariantList(DataValue *list, int len)
     return vlist;
 }
 
+QVariantMap_ *newVariantMap(DataValue *list, int len)
+{
+    // The list holds keys and values interleaved.
+    QVariantMap *vmap = new QVariantMap();
+    for (int i = 0; i+1 < len; i += 2) {
+        QVariant key, val;
+        unpackDataValue(&list[i], &key);
+        unpackDataValue(&list[i+1], &val);
+        vmap->insert(key.toString(), val);
+    }
+    return vmap;
+}
+
 QObject *listPropertyAt(QQmlListProperty<QObject> *list, int i)
 {
     return reinterpret_cast<QObject *>(hookListPropertyAt(list->data, (intptr_t)list->dummy1, (intptr_t)list->dummy2, i));
@@ -852,11 +2096,136 @@ QQmlListProperty_ *newListProperty(GoAddr *addr, intptr_t reflectIndex, intptr_t
     return list;
 }
 
+class GoListModel : public QAbstractListModel
+{
+    public:
+
+    GoListModel(QObject *parent, const QHash<int, QByteArray> &roles)
+        : QAbstractListModel(parent), roles(roles) {};
+
+    virtual ~GoListModel()
+    {
+        hookListModelDestroyed(this);
+    }
+
+    int rowCount(const QModelIndex &parent) const
+    {
+        if (parent.isValid()) {
+            return 0;
+        }
+        return hookListModelLen(const_cast<GoListModel *>(this));
+    }
+
+    QVariant data(const QModelIndex &index, int role) const
+    {
+        QVariant var;
+        if (!index.isValid() || !roles.contains(role)) {
+            return var;
+        }
+        DataValue value;
+        hookListModelData(const_cast<GoListModel *>(this), index.row(), role - Qt::UserRole - 1, &value);
+        unpackDataValue(&value, &var);
+        return var;
+    }
+
+    QHash<int, QByteArray> roleNames() const
+    {
+        return roles;
+    }
+
+    void beginInsert(int i, int n)
+    {
+        beginInsertRows(QModelIndex(), i, i+n-1);
+    }
+
+    void endInsert()
+    {
+        endInsertRows();
+    }
+
+    void beginRemove(int i, int n)
+    {
+        beginRemoveRows(QModelIndex(), i, i+n-1);
+    }
+
+    void endRemove()
+    {
+        endRemoveRows();
+    }
+
+    void changed(int i)
+    {
+        QModelIndex qindex = index(i);
+        emit dataChanged(qindex, qindex);
+    }
+
+    void beginReset()
+    {
+        beginResetModel();
+    }
+
+    void endReset()
+    {
+        endResetModel();
+    }
+
+    private:
+
+    QHash<int, QByteArray> roles;
+};
+
+QObject_ *newListModel(QQmlEngine_ *engine, char **roles, int rolesLen)
+{
+    QHash<int, QByteArray> qroles;
+    for (int i = 0; i < rolesLen; i++) {
+        qroles[Qt::UserRole + 1 + i] = QByteArray(roles[i]);
+    }
+    QObject *model = new GoListModel(reinterpret_cast<QQmlEngine *>(engine), qroles);
+    QQmlEngine::setObjectOwnership(model, QQmlEngine::CppOwnership);
+    return model;
+}
+
+void listModelBeginInsert(QObject_ *model, int i, int n)
+{
+    reinterpret_cast<GoListModel *>(model)->beginInsert(i, n);
+}
+
+void listModelEndInsert(QObject_ *model)
+{
+    reinterpret_cast<GoListModel *>(model)->endInsert();
+}
+
+void listModelBeginRemove(QObject_ *model, int i, int n)
+{
+    reinterpret_cast<GoListModel *>(model)->beginRemove(i, n);
+}
+
+void listModelEndRemove(QObject_ *model)
+{
+    reinterpret_cast<GoListModel *>(model)->endRemove();
+}
+
+void listModelChanged(QObject_ *model, int i)
+{
+    reinterpret_cast<GoListModel *>(model)->changed(i);
+}
+
+void listModelBeginReset(QObject_ *model)
+{
+    reinterpret_cast<GoListModel *>(model)->beginReset();
+}
+
+void listModelEndReset(QObject_ *model)
+{
+    reinterpret_cast<GoListModel *>(model)->endReset();
+}
+
 void internalLogHandler(QtMsgType severity, const QMessageLogContext &context, const QString &text)
 {
     QByteArray textba = text.toUtf8();
     const int fileLength = context.file ? strlen(context.file) : 0;
-    LogMessage message = {severity, textba.constData(), textba.size(), context.file, fileLength, context.line};
+    const int categoryLength = context.category ? strlen(context.category) : 0;
+    LogMessage message = {severity, textba.constData(), textba.size(), context.file, fileLength, context.line, context.category, categoryLength};
     hookLogHandler(&message);
 }
 
@@ -13,11 +13,16 @@ extern "C" {
 // This is Qt's MaximuParamCount - 1, as it does not take the result value in account.
 enum { MaxParams = 10 };
 
+// MaxTypeRevision is the highest minor version that may introduce members
+// of a registered Go type, as set via TypeSpec.Revisions.
+enum { MaxTypeRevision = 15 };
+
 typedef void QApplication_;
 typedef void QMetaObject_;
 typedef void QObject_;
 typedef void QVariant_;
 typedef void QVariantList_;
+typedef void QVariantMap_;
 typedef void QString_;
 typedef void QQmlEngine_;
 typedef void QQmlContext_;
@@ -27,9 +32,10 @@ typedef void QQuickWindow_;
 typedef void QQuickView_;
 typedef void QMessageLogContext_;
 typedef void QImage_;
+typedef void QScreen_;
+typedef void QPainter_;
 typedef void GoValue_;
 typedef void GoAddr;
-typedef void GoTypeSpec_;
 
 typedef char error;
 error *errorf(const char *format, ...);
@@ -49,6 +55,11 @@ typedef enum {
     DTFloat64 = 17,
     DTFloat32 = 18,
     DTColor   = 19,
+    DTDateTime = 20,
+    DTBytes    = 21,
+    DTPoint    = 22,
+    DTSize     = 23,
+    DTRect     = 24,
 
     DTGoAddr       = 100,
     DTObject       = 101,
@@ -56,6 +67,7 @@ typedef enum {
     DTValueList    = 103,
     DTVariantList  = 104,
     DTListProperty = 105,
+    DTVariantMap   = 106,
 
     // Used in type information, not in an actual data value.
     DTAny     = 201, // Can hold any of the above types.
@@ -80,8 +92,16 @@ typedef struct {
     char *resultSignature;
     int numIn;
     int numOut;
+    int revision;
 } GoMemberInfo;
 
+typedef struct {
+    char *enumName;
+    char **keys;
+    int *values;
+    int keysLen;
+} GoEnumInfo;
+
 typedef struct {
     char *typeName;
     GoMemberInfo *fields;
@@ -92,6 +112,9 @@ typedef struct {
     int methodsLen;
     int membersLen;
     char *memberNames;
+    GoEnumInfo *enums;
+    int enumsLen;
+    char *defaultProperty;
 
     QMetaObject_ *metaObject;
 } GoTypeInfo;
@@ -103,9 +126,53 @@ typedef struct {
     const char *file;
     int fileLen;
     int line;
+    const char *category;
+    int categoryLen;
 } LogMessage;
 
+typedef struct {
+    char *url;
+    char *description;
+    char *stack;
+    int line;
+    int column;
+} QmlError;
+
+typedef struct {
+    char *name;
+    char *typeName;
+    int writable;
+    int notifies;
+} MetaPropertyInfo;
+
+typedef struct {
+    char *name;
+    char *returnType;
+    char **paramTypes;
+    char **paramNames;
+    int paramsLen;
+    int methodType;
+} MetaMethodInfo;
+
+typedef struct {
+    char **headers; // Names and values interleaved.
+    int headersLen;
+    char *proxyHost;
+    int proxyPort;
+    int proxySocks;
+    char *proxyUser;
+    char *proxyPassword;
+    char *caCerts;
+    int caCertsLen;
+    int insecure;
+} NetworkConfig;
+
 void newGuiApplication();
+void setApplicationInfo(const char *name, const char *org, const char *version);
+const char *qtRuntimeVersion();
+void setHighDpiScaling(int enabled);
+void setHighDpiScaleFactorRoundingPolicy(int policy);
+error *setSceneGraphBackend(int backend);
 void applicationExec();
 void applicationExit();
 void applicationFlushAll();
@@ -123,33 +190,63 @@ void engineSetOwnershipJS(QQmlEngine_ *engine, QObject_ *object);
 void engineSetContextForObject(QQmlEngine_ *engine, QObject_ *object);
 void engineAddImageProvider(QQmlEngine_ *engine, QString_ *providerId, void *imageFunc);
 void engineAddImportPath(QQmlEngine_ *engine, QString_ *path);
+void engineAddPluginPath(QQmlEngine_ *engine, QString_ *path);
+void enginePluginPathList(QQmlEngine_ *engine, DataValue *result);
+void engineClearComponentCache(QQmlEngine_ *engine);
+void setPixmapCacheLimit(int kb);
+void clearPixmapCache(QQmlEngine_ *engine);
+void engineSetBaseUrl(QQmlEngine_ *engine, const char *url, int urlLen);
+void engineSetOfflineStoragePath(QQmlEngine_ *engine, QString_ *path);
+char *engineOfflineStoragePath(QQmlEngine_ *engine);
+error *engineLoadTranslation(QQmlEngine_ *engine, const char *path);
+void engineSetUILanguage(QQmlEngine_ *engine, const char *locale);
+error *engineSetNetworkConfig(QQmlEngine_ *engine, NetworkConfig *config);
 
 void contextGetProperty(QQmlContext_ *context, QString_ *name, DataValue *value);
 void contextSetProperty(QQmlContext_ *context, QString_ *name, DataValue *value);
 void contextSetObject(QQmlContext_ *context, QObject_ *value);
+error *contextSetFunc(QQmlContext_ *context, QString_ *name, QObject_ *value);
 QQmlContext_ *contextSpawn(QQmlContext_ *context);
 
 void delObject(QObject_ *object);
 void delObjectLater(QObject_ *object);
+void objectConnectDestroyed(QObject_ *object);
 const char *objectTypeName(QObject_ *object);
+QMetaObject_ *objectMetaObject(QObject_ *object);
+QObject_ *newPlainDateTime(int64_t msecs, int offset);
+void delPlainDateTime(QObject_ *plain);
+int64_t plainDateTimeMSecs(QObject_ *plain);
+MetaPropertyInfo *objectMetaProperties(QObject_ *object, int *propertiesLen);
+MetaMethodInfo *objectMetaMethods(QObject_ *object, int *methodsLen);
 int objectGetProperty(QObject_ *object, const char *name, DataValue *result);
 error *objectSetProperty(QObject_ *object, const char *name, DataValue *value);
 void objectSetParent(QObject_ *object, QObject_ *parent);
 error *objectInvoke(QObject_ *object, const char *method, int methodLen, DataValue *result, DataValue *params, int paramsLen);
+error *objectInvokeCatch(QQmlEngine_ *engine, QObject_ *object, const char *method, int methodLen, DataValue *result, DataValue *params, int paramsLen, QmlError *exception);
+error *objectEval(QQmlEngine_ *engine, QObject_ *object, const char *expr, int exprLen, DataValue *result);
 void objectFindChild(QObject_ *object, QString_ *name, DataValue *result);
+void objectChildren(QObject_ *object, DataValue *result);
 QQmlContext_ *objectContext(QObject_ *object);
 int objectIsComponent(QObject_ *object);
 int objectIsWindow(QObject_ *object);
 int objectIsView(QObject_ *object);
-error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEngine_ *engine, void *func, int argsLen);
+int objectIsItem(QObject_ *object);
+error *objectConnect(QObject_ *object, const char *signal, int signalLen, QQmlEngine_ *engine, void *func, int argsLen, QObject_ **connector);
+error *objectConnectNotify(QObject_ *object, const char *property, QQmlEngine_ *engine, void *func, QObject_ **connector);
 error *objectGoAddr(QObject_ *object, GoAddr **addr);
+void connectorDisconnect(QObject_ *connector);
+error *objectEmit(QObject_ *object, const char *signal, int signalLen, DataValue *paramsdv, int paramsLen);
 
 QQmlComponent_ *newComponent(QQmlEngine_ *engine, QObject_ *parent);
 void componentLoadURL(QQmlComponent_ *component, const char *url, int urlLen);
+QQmlComponent_ *enginePreload(QQmlEngine_ *engine, const char *url, int urlLen);
 void componentSetData(QQmlComponent_ *component, const char *data, int dataLen, const char *url, int urlLen);
-char *componentErrorString(QQmlComponent_ *component);
+int componentIsReady(QQmlComponent_ *component);
+int componentStatus(QQmlComponent_ *component);
+QmlError *componentErrors(QQmlComponent_ *component, int *errorsLen);
 QObject_ *componentCreate(QQmlComponent_ *component, QQmlContext_ *context);
 QQuickWindow_ *componentCreateWindow(QQmlComponent_ *component, QQmlContext_ *context);
+QObject_ *componentCreateWithProperties(QQmlComponent_ *component, QQmlContext_ *context, DataValue *props, error **err);
 
 void windowShow(QQuickWindow_ *win);
 void windowHide(QQuickWindow_ *win);
@@ -157,6 +254,50 @@ uintptr_t windowPlatformId(QQuickWindow_ *win);
 void windowConnectHidden(QQuickWindow_ *win);
 QObject_ *windowRootObject(QQuickWindow_ *win);
 QImage_ *windowGrabWindow(QQuickWindow_ *win);
+int windowIsExposed(QQuickWindow_ *win);
+void windowSetIcon(QQuickWindow_ *win, QImage_ **images, int imagesLen);
+error *windowSetIconFile(QQuickWindow_ *win, const char *path);
+void windowSetFullscreen(QQuickWindow_ *win, int fullscreen);
+void windowSetFrameless(QQuickWindow_ *win, int frameless);
+void windowSetAlwaysOnTop(QQuickWindow_ *win, int onTop);
+void windowSetCursorVisible(QQuickWindow_ *win, int visible);
+QObject_ *windowActiveFocusItem(QQuickWindow_ *win);
+void itemForceActiveFocus(QObject_ *item);
+void windowGeometry(QQuickWindow_ *win, int *x, int *y, int *width, int *height);
+void windowSetPosition(QQuickWindow_ *win, int x, int y);
+void windowSetGeometry(QQuickWindow_ *win, int x, int y, int width, int height);
+QScreen_ *windowScreen(QQuickWindow_ *win);
+double windowDevicePixelRatio(QQuickWindow_ *win);
+void windowConnectClose(QQuickWindow_ *win);
+void windowConnectFileDrop(QQuickWindow_ *win);
+void windowDisconnectFileDrop(QQuickWindow_ *win);
+
+void settingsValue(const char *org, const char *app, const char *key, DataValue *def, DataValue *result);
+void settingsSetValue(const char *org, const char *app, const char *key, DataValue *value);
+void settingsRemove(const char *org, const char *app, const char *key);
+error *settingsSync(const char *org, const char *app);
+
+char *clipboardText();
+void clipboardSetText(const char *text, int textLen);
+void clipboardConnectChanged();
+
+error *addApplicationFont(const char *data, int dataLen, const char *path, char **families);
+
+void applicationSetOverrideCursor(int shape);
+void applicationRestoreOverrideCursor();
+
+QScreen_ **applicationScreens(int *screensLen);
+void applicationConnectScreensChanged();
+char *screenName(QScreen_ *screen);
+void screenGeometry(QScreen_ *screen, int *x, int *y, int *width, int *height);
+void screenAvailableGeometry(QScreen_ *screen, int *x, int *y, int *width, int *height);
+double screenDevicePixelRatio(QScreen_ *screen);
+
+void painterSetRenderHint(QPainter_ *painter, int hint, int on);
+int painterRenderHints(QPainter_ *painter);
+void painterSetFont(QPainter_ *painter, const char *family, int familyLen, double pointSize);
+void painterTextExtents(QPainter_ *painter, const char *text, int textLen, double *width, double *height);
+void painterDrawText(QPainter_ *painter, double x, double y, const char *text, int textLen);
 
 QImage_ *newImage(int width, int height);
 void delImage(QImage_ *image);
@@ -175,10 +316,30 @@ void unpackDataValue(DataValue *value, QVariant_ *result);
 
 QVariantList_ *newVariantList(DataValue *list, int len);
 
+int colorFromName(const char *name, unsigned int *rgba);
+QVariantMap_ *newVariantMap(DataValue *list, int len);
+
 QQmlListProperty_ *newListProperty(GoAddr *addr, intptr_t reflectIndex, intptr_t setIndex);
 
-int registerType(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, GoTypeSpec_ *spec);
-int registerSingleton(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, GoTypeSpec_ *spec);
+QObject_ *newListModel(QQmlEngine_ *engine, char **roles, int rolesLen);
+void listModelBeginInsert(QObject_ *model, int i, int n);
+void listModelEndInsert(QObject_ *model);
+void listModelBeginRemove(QObject_ *model, int i, int n);
+void listModelEndRemove(QObject_ *model);
+void listModelChanged(QObject_ *model, int i);
+void listModelBeginReset(QObject_ *model);
+void listModelEndReset(QObject_ *model);
+
+// The spec parameters hold the index of the registered TypeSpec in the
+// Go types slice, handed back to hookGoValueTypeNew and hookSingletonFactory
+// when instances are created. The C++ side keeps these for as long as the
+// types are registered, and cgo forbids C memory from retaining pointers
+// into Go memory past the call, so the spec itself cannot be referenced.
+int registerType(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, intptr_t spec);
+int registerSingleton(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, intptr_t spec);
+int registerSingletonFactory(char *location, int major, int minor, char *name, intptr_t spec);
+int registerUncreatableType(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, intptr_t spec, char *reason);
+int registerTypeRevisions(char *location, int major, int minor, char *name, GoTypeInfo *typeInfo, intptr_t spec, char *reason, int *revisions, int revisionsLen);
 
 void installLogHandler();
 
@@ -188,17 +349,29 @@ void hookGoValueReadField(QQmlEngine_ *engine, GoAddr *addr, int memberIndex, in
 void hookGoValueWriteField(QQmlEngine_ *engine, GoAddr *addr, int memberIndex, int setIndex, DataValue *assign);
 void hookGoValueCallMethod(QQmlEngine_ *engine, GoAddr *addr, int memberIndex, DataValue *result);
 void hookGoValueDestroyed(QQmlEngine_ *engine, GoAddr *addr);
-void hookGoValuePaint(QQmlEngine_ *engine, GoAddr *addr, intptr_t reflextIndex);
-QImage_ *hookRequestImage(void *imageFunc, char *id, int idLen, int width, int height);
-GoAddr *hookGoValueTypeNew(GoValue_ *value, GoTypeSpec_ *spec);
-void hookWindowHidden(QObject_ *addr);
-void hookSignalCall(QQmlEngine_ *engine, void *func, DataValue *params);
+void hookGoValuePaint(QQmlEngine_ *engine, GoAddr *addr, intptr_t reflextIndex, QPainter_ *painter);
+QImage_ *hookRequestImage(void *imageFunc, char *id, int idLen, int width, int height, char **err);
+GoAddr *hookGoValueTypeNew(GoValue_ *value, intptr_t spec);
+QObject_ *hookSingletonFactory(QQmlEngine_ *engine, intptr_t spec);
+void hookWindowHidden(QObject_ *addr, int destroyed);
+void hookObjectDestroyed(QObject_ *addr);
+void hookClipboardChanged();
+void hookScreensChanged();
+int hookWindowClose(QObject_ *addr);
+void hookWindowCloseReleased(QObject_ *addr);
+void hookWindowFileDrop(QObject_ *addr, char **paths, int pathsLen, int x, int y);
+void hookWindowFileDropReleased(QObject_ *addr);
+void hookSignalCall(QQmlEngine_ *engine, void *func, DataValue *params, int paramsLen, char **err);
 void hookSignalDisconnect(void *func);
 void hookPanic(char *message);
 int hookListPropertyCount(GoAddr *addr, intptr_t reflectIndex, intptr_t setIndex);
 QObject_ *hookListPropertyAt(GoAddr *addr, intptr_t reflectIndex, intptr_t setIndex, int i);
 void hookListPropertyAppend(GoAddr *addr, intptr_t reflectIndex, intptr_t setIndex, QObject_ *obj);
 void hookListPropertyClear(GoAddr *addr, intptr_t reflectIndex, intptr_t setIndex);
+int hookListModelLen(QObject_ *model);
+void hookListModelData(QObject_ *model, int row, int role, DataValue *result);
+void hookListModelDestroyed(QObject_ *model);
+void hookContextDestroyed(QQmlContext_ *context);
 
 void registerResourceData(int version, char *tree, char *name, char *data);
 void unregisterResourceData(int version, char *tree, char *name, char *data);
@@ -1,4 +1,5 @@
 #include <QObject>
+#include <stdlib.h>
 
 #include "connector.h"
 #include "capi.h"
@@ -34,7 +35,12 @@ int Connector::qt_metacall(QMetaObject::Call c, int idx, void **a)
                 packDataValue(&var, &args[i]);
             }
         }
-        hookSignalCall(engine, func, args);
+        char *err = 0;
+        hookSignalCall(engine, func, args, argsLen, &err);
+        if (err) {
+            qWarning("%s", err);
+            free(err);
+        }
         if (plain != NULL) {
                 delete plain;
         }
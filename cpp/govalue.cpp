@@ -161,7 +161,7 @@ void GoPaintedValue::activate(int propIndex)
 void GoPaintedValue::paint(QPainter *painter)
 {
     painter->beginNativePainting();
-    hookGoValuePaint(qmlEngine(this), addr, typeInfo->paint->reflectIndex);
+    hookGoValuePaint(qmlEngine(this), addr, typeInfo->paint->reflectIndex, painter);
     painter->endNativePainting();
 }
 
@@ -185,13 +185,15 @@ QMetaObject *metaObjectFor(GoTypeInfo *typeInfo)
     memberInfo = typeInfo->fields;
     int relativePropIndex = mob.propertyCount();
     for (int i = 0; i < typeInfo->fieldsLen; i++) {
-        mob.addSignal("__" + QByteArray::number(relativePropIndex) + "()");
+        QMetaMethodBuilder signalb = mob.addSignal("__" + QByteArray::number(relativePropIndex) + "()");
+        signalb.setRevision(memberInfo->revision);
         const char *typeName = "QVariant";
         if (memberInfo->memberType == DTListProperty) {
             typeName = "QQmlListProperty<QObject>";
         }
         QMetaPropertyBuilder propb = mob.addProperty(memberInfo->memberName, typeName, relativePropIndex);
         propb.setWritable(true);
+        propb.setRevision(memberInfo->revision);
         memberInfo->metaIndex = relativePropIndex;
         memberInfo++;
         relativePropIndex++;
@@ -200,18 +202,30 @@ QMetaObject *metaObjectFor(GoTypeInfo *typeInfo)
     memberInfo = typeInfo->methods;
     int relativeMethodIndex = mob.methodCount();
     for (int i = 0; i < typeInfo->methodsLen; i++) {
+        QMetaMethodBuilder methodb;
         if (*memberInfo->resultSignature) {
-            mob.addMethod(memberInfo->methodSignature, memberInfo->resultSignature);
+            methodb = mob.addMethod(memberInfo->methodSignature, memberInfo->resultSignature);
         } else {
-            mob.addMethod(memberInfo->methodSignature);
+            methodb = mob.addMethod(memberInfo->methodSignature);
         }
+        methodb.setRevision(memberInfo->revision);
         memberInfo->metaIndex = relativeMethodIndex;
         memberInfo++;
         relativeMethodIndex++;
     }
 
-    // TODO Support default properties.
-    //mob.addClassInfo("DefaultProperty", "objects");
+    GoEnumInfo *enumInfo = typeInfo->enums;
+    for (int i = 0; i < typeInfo->enumsLen; i++) {
+        QMetaEnumBuilder enumb = mob.addEnumerator(enumInfo->enumName);
+        for (int j = 0; j < enumInfo->keysLen; j++) {
+            enumb.addKey(enumInfo->keys[j], enumInfo->values[j]);
+        }
+        enumInfo++;
+    }
+
+    if (typeInfo->defaultProperty) {
+        mob.addClassInfo("DefaultProperty", typeInfo->defaultProperty);
+    }
 
     QMetaObject *mo = mob.toMetaObject();
 
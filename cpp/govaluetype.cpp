@@ -3,12 +3,12 @@
 #define DEFINE_GOVALUETYPE(N) \
     template<> QMetaObject GoValueType<N>::staticMetaObject = QMetaObject(); \
     template<> GoTypeInfo *GoValueType<N>::typeInfo = 0; \
-    template<> GoTypeSpec_ *GoValueType<N>::typeSpec = 0;
+    template<> intptr_t GoValueType<N>::typeSpec = 0;
 
 #define DEFINE_GOPAINTEDVALUETYPE(N) \
     template<> QMetaObject GoPaintedValueType<N>::staticMetaObject = QMetaObject(); \
     template<> GoTypeInfo *GoPaintedValueType<N>::typeInfo = 0; \
-    template<> GoTypeSpec_ *GoPaintedValueType<N>::typeSpec = 0;
+    template<> intptr_t GoPaintedValueType<N>::typeSpec = 0;
 
 DEFINE_GOVALUETYPE(1)
 DEFINE_GOVALUETYPE(2)
@@ -76,7 +76,7 @@ static int goValueTypeN = 0;
 static int goPaintedValueTypeN = 0;
 
 template<int N>
-int registerSingletonN(char *location, int major, int minor, char *name, GoTypeInfo *info, GoTypeSpec_ *spec) {
+int registerSingletonN(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec) {
     GoValueType<N>::init(info, spec);
     return qmlRegisterSingletonType< GoValueType<N> >(location, major, minor, name, [](QQmlEngine *qmlEngine, QJSEngine *jsEngine) -> QObject* {
         QObject *singleton = new GoValueType<N>();
@@ -86,7 +86,7 @@ int registerSingletonN(char *location, int major, int minor, char *name, GoTypeI
 }
 
 template<int N>
-int registerPaintedSingletonN(char *location, int major, int minor, char *name, GoTypeInfo *info, GoTypeSpec_ *spec) {
+int registerPaintedSingletonN(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec) {
     GoPaintedValueType<N>::init(info, spec);
     return qmlRegisterSingletonType< GoPaintedValueType<N> >(location, major, minor, name, [](QQmlEngine *qmlEngine, QJSEngine *jsEngine) -> QObject* {
         QObject *singleton = new GoPaintedValueType<N>();
@@ -100,7 +100,7 @@ int registerPaintedSingletonN(char *location, int major, int minor, char *name,
 #define GOPAINTEDVALUETYPE_CASE_SINGLETON(N) \
         case N: return registerPaintedSingletonN<N>(location, major, minor, name, info, spec);
 
-int registerSingleton(char *location, int major, int minor, char *name, GoTypeInfo *info, GoTypeSpec_ *spec)
+int registerSingleton(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec)
 {
     if (!info->paint) {
         switch (++goValueTypeN) {
@@ -173,12 +173,65 @@ int registerSingleton(char *location, int major, int minor, char *name, GoTypeIn
     return 0;
 }
 
+static int goSingletonFactoryN = 0;
+static intptr_t goSingletonFactorySpec[31];
+
+template<int N>
+int registerSingletonFactoryN(char *location, int major, int minor, char *name, intptr_t spec) {
+    goSingletonFactorySpec[N] = spec;
+    return qmlRegisterSingletonType(location, major, minor, name, [](QQmlEngine *qmlEngine, QJSEngine *jsEngine) -> QJSValue {
+        QObject *singleton = reinterpret_cast<QObject *>(hookSingletonFactory(qmlEngine, goSingletonFactorySpec[N]));
+        return qmlEngine->newQObject(singleton);
+    });
+}
+
+#define GOSINGLETONFACTORY_CASE(N) \
+        case N: return registerSingletonFactoryN<N>(location, major, minor, name, spec);
+
+int registerSingletonFactory(char *location, int major, int minor, char *name, intptr_t spec)
+{
+    switch (++goSingletonFactoryN) {
+        GOSINGLETONFACTORY_CASE(1)
+        GOSINGLETONFACTORY_CASE(2)
+        GOSINGLETONFACTORY_CASE(3)
+        GOSINGLETONFACTORY_CASE(4)
+        GOSINGLETONFACTORY_CASE(5)
+        GOSINGLETONFACTORY_CASE(6)
+        GOSINGLETONFACTORY_CASE(7)
+        GOSINGLETONFACTORY_CASE(8)
+        GOSINGLETONFACTORY_CASE(9)
+        GOSINGLETONFACTORY_CASE(10)
+        GOSINGLETONFACTORY_CASE(11)
+        GOSINGLETONFACTORY_CASE(12)
+        GOSINGLETONFACTORY_CASE(13)
+        GOSINGLETONFACTORY_CASE(14)
+        GOSINGLETONFACTORY_CASE(15)
+        GOSINGLETONFACTORY_CASE(16)
+        GOSINGLETONFACTORY_CASE(17)
+        GOSINGLETONFACTORY_CASE(18)
+        GOSINGLETONFACTORY_CASE(19)
+        GOSINGLETONFACTORY_CASE(20)
+        GOSINGLETONFACTORY_CASE(21)
+        GOSINGLETONFACTORY_CASE(22)
+        GOSINGLETONFACTORY_CASE(23)
+        GOSINGLETONFACTORY_CASE(24)
+        GOSINGLETONFACTORY_CASE(25)
+        GOSINGLETONFACTORY_CASE(26)
+        GOSINGLETONFACTORY_CASE(27)
+        GOSINGLETONFACTORY_CASE(28)
+        GOSINGLETONFACTORY_CASE(29)
+        GOSINGLETONFACTORY_CASE(30)
+    }
+    panicf("too many registered singletons; please contact the Go QML developers");
+    return 0;
+}
+
 #define GOVALUETYPE_CASE(N) \
     case N: GoValueType<N>::init(info, spec); return qmlRegisterType< GoValueType<N> >(location, major, minor, name);
 #define GOPAINTEDVALUETYPE_CASE(N) \
     case N: GoPaintedValueType<N>::init(info, spec); return qmlRegisterType< GoPaintedValueType<N> >(location, major, minor, name);
 
-int registerType(char *location, int major, int minor, char *name, GoTypeInfo *info, GoTypeSpec_ *spec)
+int registerType(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec)
 {
     if (!info->paint) {
         switch (++goValueTypeN) {
@@ -251,4 +304,96 @@ int registerType(char *location, int major, int minor, char *name, GoTypeInfo *i
     return 0;
 }
 
+// GoTypeRevision registers T under major.minor with the members up to the
+// provided revision, which must not be greater than R. Qt's registration
+// functions take the revision as a template parameter, so this picks the
+// instance of them matching the revision known at runtime.
+template<typename T, int R>
+struct GoTypeRevision
+{
+    static int registerType(const char *uri, int major, int minor, const char *name, const char *reason, int revision)
+    {
+        if (revision < R) {
+            return GoTypeRevision<T, R-1>::registerType(uri, major, minor, name, reason, revision);
+        }
+        if (reason) {
+            return qmlRegisterUncreatableType<T, R>(uri, major, minor, name, QString::fromUtf8(reason));
+        }
+        return qmlRegisterType<T, R>(uri, major, minor, name);
+    }
+};
+
+template<typename T>
+struct GoTypeRevision<T, 0>
+{
+    static int registerType(const char *uri, int major, int minor, const char *name, const char *reason, int revision)
+    {
+        if (reason) {
+            return qmlRegisterUncreatableType<T>(uri, major, minor, name, QString::fromUtf8(reason));
+        }
+        return qmlRegisterType<T>(uri, major, minor, name);
+    }
+};
+
+// registerTypeVersions registers T under major.minor with the unrevisioned
+// members, and then again under major.revision for each of the provided
+// revisions, with the members up to that revision.
+template<typename T>
+int registerTypeVersions(char *location, int major, int minor, char *name, char *reason, int *revisions, int revisionsLen)
+{
+    int result = GoTypeRevision<T, 0>::registerType(location, major, minor, name, reason, 0);
+    for (int i = 0; i < revisionsLen && result != -1; i++) {
+        if (revisions[i] > MaxTypeRevision) {
+            panicf("type revision %d is not supported", revisions[i]);
+        }
+        result = GoTypeRevision<T, MaxTypeRevision>::registerType(location, major, revisions[i], name, reason, revisions[i]);
+    }
+    return result;
+}
+
+// GoTypeSlot initializes and registers the template instance numbered n,
+// for n up to N, of GoValueType or GoPaintedValueType, depending on whether
+// the Go type paints. Qt tells registered types apart by their C++ type, so
+// each registration takes an instance of its own.
+template<int N>
+struct GoTypeSlot
+{
+    static int registerType(int n, char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec, char *reason, int *revisions, int revisionsLen)
+    {
+        if (n < N) {
+            return GoTypeSlot<N-1>::registerType(n, location, major, minor, name, info, spec, reason, revisions, revisionsLen);
+        }
+        if (info->paint) {
+            GoPaintedValueType<N>::init(info, spec);
+            return registerTypeVersions< GoPaintedValueType<N> >(location, major, minor, name, reason, revisions, revisionsLen);
+        }
+        GoValueType<N>::init(info, spec);
+        return registerTypeVersions< GoValueType<N> >(location, major, minor, name, reason, revisions, revisionsLen);
+    }
+};
+
+template<>
+struct GoTypeSlot<0>
+{
+    static int registerType(int n, char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec, char *reason, int *revisions, int revisionsLen)
+    {
+        panicf("invalid type slot %d", n);
+        return 0;
+    }
+};
+
+int registerTypeRevisions(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec, char *reason, int *revisions, int revisionsLen)
+{
+    int n = info->paint ? ++goPaintedValueTypeN : ++goValueTypeN;
+    if (n > 30) {
+        panicf("too many registered types; please contact the Go QML developers");
+    }
+    return GoTypeSlot<30>::registerType(n, location, major, minor, name, info, spec, reason, revisions, revisionsLen);
+}
+
+int registerUncreatableType(char *location, int major, int minor, char *name, GoTypeInfo *info, intptr_t spec, char *reason)
+{
+    return registerTypeRevisions(location, major, minor, name, info, spec, reason, 0, 0);
+}
+
 // vim:sw=4:st=4:et:ft=cpp
@@ -1,5 +1,10 @@
 #include <string.h>
 
+#include <QCoreApplication>
+#include <QCloseEvent>
+#include <QDropEvent>
+#include <QMimeData>
+
 #include "cpptest.h"
 #include "testtype.h"
 
@@ -12,3 +17,29 @@ int plainTestTypeN(PlainTestType_ *plain)
 {
 	return static_cast<PlainTestType *>(plain)->n;
 }
+
+int sendCloseEvent(void *obj)
+{
+	QCloseEvent event;
+	QCoreApplication::sendEvent(static_cast<QObject *>(obj), &event);
+	return event.isAccepted();
+}
+
+int sendFileDrop(void *obj, char **urls, int urlsLen, int x, int y)
+{
+	QList<QUrl> qurls;
+	for (int i = 0; i < urlsLen; i++) {
+		qurls.append(QUrl(QString::fromUtf8(urls[i])));
+	}
+	QMimeData mime;
+	mime.setUrls(qurls);
+
+	QDragEnterEvent enter(QPoint(x, y), Qt::CopyAction, &mime, Qt::LeftButton, Qt::NoModifier);
+	QCoreApplication::sendEvent(static_cast<QObject *>(obj), &enter);
+	if (!enter.isAccepted()) {
+		return 0;
+	}
+	QDropEvent drop(QPointF(x, y), Qt::CopyAction, &mime, Qt::LeftButton, Qt::NoModifier);
+	QCoreApplication::sendEvent(static_cast<QObject *>(obj), &drop);
+	return drop.isAccepted();
+}
@@ -4,7 +4,9 @@ package cpptest
 // #cgo CXXFLAGS: -std=c++0x -Wall -fno-strict-aliasing -I..
 // #cgo LDFLAGS: -lstdc++
 //
-// #cgo pkg-config: Qt5Core
+// #cgo pkg-config: Qt5Core Qt5Gui
+//
+// #include <stdlib.h>
 //
 // #include "cpptest.h"
 //
@@ -28,3 +30,32 @@ func NewTestType(engine *qml.Engine) qml.Object {
 func PlainTestTypeN(obj qml.Object) int {
 	return int(C.plainTestTypeN(unsafe.Pointer(obj.Property("plainAddr").(uintptr))))
 }
+
+// SendCloseEvent delivers a close event to obj, as the window system
+// does when the user asks for a window to be closed, and reports
+// whether the event was accepted.
+func SendCloseEvent(obj qml.Object) bool {
+	var accepted bool
+	qml.RunMain(func() {
+		accepted = C.sendCloseEvent(unsafe.Pointer(obj.Common().Addr())) != 0
+	})
+	return accepted
+}
+
+// SendFileDrop delivers to obj the events the window system sends when
+// the provided URLs are dragged onto it and dropped at x, y, and reports
+// whether the drop was accepted.
+func SendFileDrop(obj qml.Object, urls []string, x, y int) bool {
+	curls := make([]*C.char, len(urls)+1)
+	for i, url := range urls {
+		curls[i] = C.CString(url)
+	}
+	var accepted bool
+	qml.RunMain(func() {
+		accepted = C.sendFileDrop(unsafe.Pointer(obj.Common().Addr()), &curls[0], C.int(len(urls)), C.int(x), C.int(y)) != 0
+	})
+	for _, curl := range curls[:len(urls)] {
+		C.free(unsafe.Pointer(curl))
+	}
+	return accepted
+}
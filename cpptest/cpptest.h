@@ -16,6 +16,10 @@ TestType_ *newTestType();
 
 int plainTestTypeN(PlainTestType_ *plain);
 
+int sendCloseEvent(void *obj);
+
+int sendFileDrop(void *obj, char **urls, int urlsLen, int x, int y);
+
 #ifdef __cplusplus
 }
 #endif
@@ -10,6 +10,7 @@ import (
 	"image/color"
 	"reflect"
 	"strings"
+	"time"
 	"unicode"
 	"unsafe"
 )
@@ -32,12 +33,17 @@ var (
 	typeFloat32    = reflect.TypeOf(float32(0))
 	typeIface      = reflect.TypeOf(new(interface{})).Elem()
 	typeRGBA       = reflect.TypeOf(color.RGBA{})
+	typeTime       = reflect.TypeOf(time.Time{})
+	typePoint      = reflect.TypeOf(Point{})
+	typeSize       = reflect.TypeOf(Size{})
+	typeRect       = reflect.TypeOf(Rect{})
 	typeObjSlice   = reflect.TypeOf([]Object(nil))
 	typeObject     = reflect.TypeOf([]Object(nil)).Elem()
 	typePainter    = reflect.TypeOf(&Painter{})
 	typeList       = reflect.TypeOf(&List{})
 	typeMap        = reflect.TypeOf(&Map{})
 	typeGenericMap = reflect.TypeOf(map[string]interface{}(nil))
+	typeError      = reflect.TypeOf((*error)(nil)).Elem()
 )
 
 func init() {
@@ -54,15 +60,21 @@ func init() {
 // shiping into C++ land.
 //
 // For simple types (bool, int, etc) value is converted into a
-// native C++ value. For anything else, including cases when value
-// has a type that has an underlying simple type, the Go value itself
-// is encapsulated into a C++ wrapper so that field access and method
-// calls work.
+// native C++ value. Slices and maps with string keys are converted
+// into a QVariantList and a QVariantMap respectively, with their
+// elements packed recursively, unless they have a named type with
+// exported methods (see nativeContainer). For anything else, including cases
+// when value has a type that has an underlying simple type, the Go
+// value itself is encapsulated into a C++ wrapper so that field
+// access and method calls work.
 //
 // This must be run from the main GUI thread due to the cases where
 // calling wrapGoValue is necessary.
 func packDataValue(value interface{}, dvalue *C.DataValue, engine *Engine, owner valueOwner) {
 	datap := unsafe.Pointer(&dvalue.data)
+	if value != nil {
+		value = convertGoValue(engine, value)
+	}
 	if value == nil {
 		dvalue.dataType = C.DTInvalid
 		return
@@ -108,17 +120,107 @@ func packDataValue(value interface{}, dvalue *C.DataValue, engine *Engine, owner
 	case color.RGBA:
 		dvalue.dataType = C.DTColor
 		*(*uint32)(datap) = uint32(value.A)<<24 | uint32(value.R)<<16 | uint32(value.G)<<8 | uint32(value.B)
+	case []byte:
+		dvalue.dataType = C.DTBytes
+		if len(value) > 0 {
+			*(**C.char)(datap) = (*C.char)(unsafe.Pointer(&value[0]))
+		} else {
+			*(**C.char)(datap) = nilCharPtr
+		}
+		dvalue.len = C.int(len(value))
+	case Point:
+		dvalue.dataType = C.DTPoint
+		*(*unsafe.Pointer)(datap) = newCoords(value.X, value.Y)
+	case Size:
+		dvalue.dataType = C.DTSize
+		*(*unsafe.Pointer)(datap) = newCoords(value.Width, value.Height)
+	case Rect:
+		dvalue.dataType = C.DTRect
+		*(*unsafe.Pointer)(datap) = newCoords(value.X, value.Y, value.Width, value.Height)
+	case time.Time:
+		// Qt only has millisecond resolution, so the rest is truncated.
+		dvalue.dataType = C.DTDateTime
+		*(*int64)(datap) = value.Unix()*1000 + int64(value.Nanosecond()/1e6)
+		_, offset := value.Zone()
+		dvalue.len = C.int(offset)
 	default:
-		dvalue.dataType = C.DTObject
 		if obj, ok := value.(Object); ok {
+			dvalue.dataType = C.DTObject
 			*(*unsafe.Pointer)(datap) = obj.Common().addr
-		} else {
+			break
+		}
+		v := reflect.ValueOf(value)
+		switch {
+		case v.Kind() == reflect.Slice && nativeContainer(v.Type()):
+			dvlist := newDataValues(v.Len())
+			for i := range dvlist {
+				packDataValue(v.Index(i).Interface(), &dvlist[i], engine, owner)
+			}
+			// dvlist has room for one more value, so this holds even when empty.
+			dvlistp := &dvlist[:1][0]
+			dvalue.dataType = C.DTVariantList
+			*(*unsafe.Pointer)(datap) = C.newVariantList(dvlistp, C.int(len(dvlist)))
+			C.free(unsafe.Pointer(dvlistp))
+		case v.Kind() == reflect.Map && nativeContainer(v.Type()):
+			// Keys and values are interleaved.
+			dvlist := newDataValues(v.Len() * 2)
+			for i, key := range v.MapKeys() {
+				packDataValue(key.String(), &dvlist[i*2], engine, owner)
+				packDataValue(v.MapIndex(key).Interface(), &dvlist[i*2+1], engine, owner)
+			}
+			dvlistp := &dvlist[:1][0]
+			dvalue.dataType = C.DTVariantMap
+			*(*unsafe.Pointer)(datap) = C.newVariantMap(dvlistp, C.int(len(dvlist)))
+			C.free(unsafe.Pointer(dvlistp))
+		case v.Kind() == reflect.Struct && valueTypes[v.Type()] != nil:
+			packDataValue(valueTypeFields(v), dvalue, engine, owner)
+		default:
+			dvalue.dataType = C.DTObject
 			*(*unsafe.Pointer)(datap) = wrapGoValue(engine, value, owner)
 		}
 	}
 }
 
-// TODO Handle byte slices.
+// nativeContainer returns whether values of the slice or map type t are
+// handed over to QML as lists and objects holding copies of their elements.
+// Maps must have string keys for that. Named types with exported methods,
+// such as a slice type with a Total method, are handed over by reference
+// instead, so that their methods remain available to QML.
+func nativeContainer(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return false
+		}
+	default:
+		return false
+	}
+	return t.Name() == "" || reflect.PtrTo(t).NumMethod() == 0
+}
+
+// valueTypeFields returns the fields of v, a value of a type registered
+// with RegisterValueType, keyed by the names QML knows them as.
+func valueTypeFields(v reflect.Value) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		if name, ok := fieldName(v.Type().Field(i)); ok {
+			fields[name] = v.Field(i).Interface()
+		}
+	}
+	return fields
+}
+
+// newDataValues returns a slice of n data values allocated in C memory,
+// with room for at least one value. It must be released with C.free.
+func newDataValues(n int) []C.DataValue {
+	var dvlist []C.DataValue
+	dvlisth := (*reflect.SliceHeader)(unsafe.Pointer(&dvlist))
+	dvlisth.Data = uintptr(C.malloc(C.size_t(dataValueSize) * C.size_t(n+1)))
+	dvlisth.Len = n
+	dvlisth.Cap = n + 1
+	return dvlist
+}
 
 // unpackDataValue converts a value shipped by C++ into a native Go value.
 //
@@ -152,6 +254,25 @@ func unpackDataValue(dvalue *C.DataValue, engine *Engine) interface{} {
 	case C.DTColor:
 		var c uint32 = *(*uint32)(datap)
 		return color.RGBA{byte(c >> 16), byte(c >> 8), byte(c), byte(c >> 24)}
+	case C.DTBytes:
+		b := C.GoBytes(*(*unsafe.Pointer)(datap), dvalue.len)
+		C.free(*(*unsafe.Pointer)(datap))
+		return b
+	case C.DTPoint:
+		c := takeCoords(*(*unsafe.Pointer)(datap), 2)
+		return Point{c[0], c[1]}
+	case C.DTSize:
+		c := takeCoords(*(*unsafe.Pointer)(datap), 2)
+		return Size{c[0], c[1]}
+	case C.DTRect:
+		c := takeCoords(*(*unsafe.Pointer)(datap), 4)
+		return Rect{c[0], c[1], c[2], c[3]}
+	case C.DTDateTime:
+		// Dates are handed to the converter registered for QDateTime,
+		// which is convertDateTime unless replaced via RegisterConverter.
+		plain := C.newPlainDateTime(*(*C.int64_t)(datap), dvalue.len)
+		defer C.delPlainDateTime(plain)
+		return converters["QDateTime"](engine, CommonOf(plain, engine))
 	case C.DTGoAddr:
 		// ObjectByName also does this fold conversion, to have access
 		// to the cvalue. Perhaps the fold should be returned.
@@ -162,11 +283,8 @@ func unpackDataValue(dvalue *C.DataValue, engine *Engine) interface{} {
 		return nil
 	case C.DTObject:
 		// TODO Would be good to preserve identity on the Go side. See initGoType as well.
-		obj := &Common{
-			engine: engine,
-			addr:   *(*unsafe.Pointer)(datap),
-		}
-		if len(converters) > 0 {
+		obj := CommonOf(*(*unsafe.Pointer)(datap), engine)
+		if len(converters) > len(defaultConverters) {
 			// TODO Embed the type name in DataValue to drop these calls.
 			typeName := obj.TypeName()
 			if typeName == "PlainObject" {
@@ -222,9 +340,20 @@ func dataTypeOf(typ reflect.Type) C.DataType {
 		return C.DTAny
 	case typeRGBA:
 		return C.DTColor
+	case typeTime:
+		return C.DTDateTime
+	case typePoint:
+		return C.DTPoint
+	case typeSize:
+		return C.DTSize
+	case typeRect:
+		return C.DTRect
 	case typeObjSlice:
 		return C.DTListProperty
 	}
+	if valueTypes[typ] != nil {
+		return C.DTAny
+	}
 	return C.DTObject
 }
 
@@ -253,6 +382,39 @@ func appendLoweredName(buf []byte, name string) []byte {
 	return append(buf, string(unicode.ToLower(last))...)
 }
 
+// fieldName returns the name QML knows the struct field as, and whether
+// the field is visible to QML at all. The name is the field name with its
+// first letter lowercased, unless the field has a tag such as `qml:"name"`,
+// in which case that name is used instead. Fields that are not exported or
+// that are tagged with `qml:"-"` are not visible. Options following the
+// name in the tag, as in `qml:"name,notify"`, are ignored here.
+func fieldName(field reflect.StructField) (name string, ok bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	name = field.Tag.Get("qml")
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = string(appendLoweredName(nil, field.Name))
+	}
+	return name, true
+}
+
+// checkFieldOptions panics if the qml tag of field has unknown options.
+func checkFieldOptions(vt reflect.Type, field reflect.StructField) {
+	options := strings.Split(field.Tag.Get("qml"), ",")[1:]
+	for _, option := range options {
+		if option != "notify" {
+			panic(fmt.Sprintf("unknown option %q in qml tag of field %s.%s", option, vt, field.Name))
+		}
+	}
+}
+
 func typeInfo(v interface{}) *C.GoTypeInfo {
 	vt := reflect.TypeOf(v)
 	for vt.Kind() == reflect.Ptr {
@@ -268,6 +430,9 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 	typeInfo.typeName = C.CString(vt.Name())
 	typeInfo.metaObject = nilPtr
 	typeInfo.paint = (*C.GoMemberInfo)(nilPtr)
+	typeInfo.enums = (*C.GoEnumInfo)(nilPtr)
+	typeInfo.enumsLen = 0
+	typeInfo.defaultProperty = nilCharPtr
 
 	var setters map[string]int
 	var getters map[string]int
@@ -284,15 +449,19 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 	privateFields := 0
 	privateMethods := 0
 
-	// struct { FooBar T; Baz T } => "fooBar\0baz\0"
+	// struct { FooBar T; Baz T `qml:"buz"` } => "fooBar\0buz\0"
 	namesLen := 0
+	fieldNames := make([]string, numField)
 	for i := 0; i < numField; i++ {
 		field := vt.Field(i)
-		if field.PkgPath != "" {
+		name, ok := fieldName(field)
+		if !ok {
 			privateFields++
 			continue
 		}
-		namesLen += len(field.Name) + 1
+		checkFieldOptions(vt, field)
+		fieldNames[i] = name
+		namesLen += len(name) + 1
 	}
 	for i := 0; i < numMethod; i++ {
 		method := vtptr.Method(i)
@@ -319,11 +488,10 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 	}
 	names := make([]byte, 0, namesLen)
 	for i := 0; i < numField; i++ {
-		field := vt.Field(i)
-		if field.PkgPath != "" {
-			continue // not exported
+		if fieldNames[i] == "" {
+			continue // not exported or hidden
 		}
-		names = appendLoweredName(names, field.Name)
+		names = append(names, fieldNames[i]...)
 		names = append(names, 0)
 	}
 	for i := 0; i < numMethod; i++ {
@@ -366,8 +534,8 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 	mnames := uintptr(unsafe.Pointer(typeInfo.memberNames))
 	for i := 0; i < numField; i++ {
 		field := vt.Field(i)
-		if field.PkgPath != "" {
-			continue // not exported
+		if fieldNames[i] == "" {
+			continue // not exported or hidden
 		}
 		memberInfo := (*C.GoMemberInfo)(unsafe.Pointer(members + uintptr(memberInfoSize)*membersi))
 		memberInfo.memberName = (*C.char)(unsafe.Pointer(mnames + mnamesi))
@@ -376,8 +544,9 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 		memberInfo.reflectGetIndex = -1
 		memberInfo.reflectSetIndex = -1
 		memberInfo.addrOffset = C.int(field.Offset)
+		memberInfo.revision = 0
 		membersi += 1
-		mnamesi += uintptr(len(field.Name)) + 1
+		mnamesi += uintptr(len(fieldNames[i])) + 1
 		if methodIndex, ok := setters[field.Name]; ok {
 			memberInfo.reflectSetIndex = C.int(methodIndex)
 		}
@@ -397,6 +566,7 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 		memberInfo.reflectGetIndex = C.int(getters[method.Name])
 		memberInfo.reflectSetIndex = C.int(setters[method.Name])
 		memberInfo.addrOffset = 0
+		memberInfo.revision = 0
 		membersi += 1
 		mnamesi += uintptr(len(method.Name)) + 1
 	}
@@ -415,6 +585,7 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 		memberInfo.reflectGetIndex = -1
 		memberInfo.reflectSetIndex = -1
 		memberInfo.addrOffset = 0
+		memberInfo.revision = 0
 		signature, result := methodQtSignature(method)
 		// TODO The signature data might be embedded in the same array as the member names.
 		memberInfo.methodSignature = C.CString(signature)
@@ -452,6 +623,41 @@ func typeInfo(v interface{}) *C.GoTypeInfo {
 	return typeInfo
 }
 
+// typeInfoCopy returns a copy of info with its own members and meta object,
+// so that it may be customized for a single type registration.
+func typeInfoCopy(info *C.GoTypeInfo) *C.GoTypeInfo {
+	infoCopy := (*C.GoTypeInfo)(C.malloc(typeInfoSize))
+	*infoCopy = *info
+	infoCopy.metaObject = nilPtr
+
+	from := uintptr(unsafe.Pointer(info.members))
+	members := uintptr(C.malloc(memberInfoSize * C.size_t(info.membersLen)))
+	for i := uintptr(0); i < uintptr(info.membersLen); i++ {
+		offset := uintptr(memberInfoSize) * i
+		*(*C.GoMemberInfo)(unsafe.Pointer(members + offset)) = *(*C.GoMemberInfo)(unsafe.Pointer(from + offset))
+	}
+	infoCopy.members = (*C.GoMemberInfo)(unsafe.Pointer(members))
+	infoCopy.fields = infoCopy.members
+	infoCopy.methods = (*C.GoMemberInfo)(unsafe.Pointer(members + uintptr(memberInfoSize)*uintptr(info.fieldsLen)))
+	if info.paint != nil {
+		infoCopy.paint = (*C.GoMemberInfo)(unsafe.Pointer(members + uintptr(unsafe.Pointer(info.paint)) - from))
+	}
+	return infoCopy
+}
+
+// memberInfo returns the information for the named member of info,
+// or nil if info has no such member.
+func memberInfo(info *C.GoTypeInfo, name string) *C.GoMemberInfo {
+	members := uintptr(unsafe.Pointer(info.members))
+	for i := uintptr(0); i < uintptr(info.membersLen); i++ {
+		member := (*C.GoMemberInfo)(unsafe.Pointer(members + uintptr(memberInfoSize)*i))
+		if C.GoString(member.memberName) == name {
+			return member
+		}
+	}
+	return nil
+}
+
 func methodQtSignature(method reflect.Method) (signature, result string) {
 	var buf bytes.Buffer
 	for i, rune := range method.Name {
@@ -139,6 +139,25 @@
 //    value.UPPERName => value.upperName
 //    value.UPPER     => value.upper
 //
+// A field may be published under a different name, or hidden from QML
+// altogether, via a qml tag in its struct definition:
+//
+//    type Person struct {
+//            Name     string `qml:"fullName"`
+//            Password string `qml:"-"`
+//    }
+//
+// The name in the tag may be followed by the notify option, as in
+// `qml:"fullName,notify"`. Every published field has a change notification
+// signal, emitted whenever QML code writes to the field. With the notify
+// option, the signal is also emitted whenever Go logic invoked by QML code,
+// such as a setter method or any other method of the value, leaves the
+// field with a different value, so that QML bindings depending on the field
+// are updated without further work. Changes are found by comparing field
+// values before and after the Go logic runs, so modifying the elements of
+// a slice in place is not noticed. Go code changing fields on its own must
+// still call the Changed function for them.
+//
 //
 // Setters and getters
 //
@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package qml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Get returns the value of the given object property converted to T,
+// or the zero T and an error if the property does not exist or its value
+// cannot be converted. For example:
+//
+//     width, err := qml.Get[float64](win, "width")
+//
+// Values are converted as done by CallAs, so lists and maps may be
+// obtained as Go slices, maps, and structs.
+func Get[T any](o Object, property string) (T, error) {
+	var result T
+	obj := o.Common()
+	if obj.destroyed() {
+		return result, errDestroyed
+	}
+	value, ok := obj.LookupProperty(property)
+	if !ok {
+		return result, fmt.Errorf("object does not have a %q property", property)
+	}
+	if err := convertResult(reflect.ValueOf(&result).Elem(), value); err != nil {
+		return result, fmt.Errorf("cannot convert property %s: %v", property, err)
+	}
+	return result, nil
+}
+
+// CallAs calls the given object method with the provided parameters and
+// returns its result converted to T, or the zero T and an error as the
+// CallAs method would report. For example:
+//
+//     names, err := qml.CallAs[[]string](obj, "names")
+func CallAs[T any](o Object, method string, params ...interface{}) (T, error) {
+	var result T
+	err := o.Common().CallAs(method, &result, params...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+//go:build go1.18
+// +build go1.18
+
+package qml_test
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/qml.v1"
+)
+
+func (s *S) TestGenericGet(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			width: 300
+			property string label: "<label>"
+			property var tags: ["x", "y"]
+			property var record: {"name": "<name>", "count": 2}
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+
+	width, err := qml.Get[float64](root, "width")
+	c.Assert(err, IsNil)
+	c.Assert(width, Equals, 300.0)
+
+	label, err := qml.Get[string](root, "label")
+	c.Assert(err, IsNil)
+	c.Assert(label, Equals, "<label>")
+
+	tags, err := qml.Get[[]string](root, "tags")
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, []string{"x", "y"})
+
+	type Record struct {
+		Name  string
+		Count int
+	}
+	record, err := qml.Get[Record](root, "record")
+	c.Assert(err, IsNil)
+	c.Assert(record, Equals, Record{Name: "<name>", Count: 2})
+
+	n, err := qml.Get[int](root, "tags")
+	c.Assert(err, ErrorMatches, "cannot convert property tags: cannot use \\*qml.List as a int")
+	c.Assert(n, Equals, 0)
+
+	_, err = qml.Get[string](root, "missing")
+	c.Assert(err, ErrorMatches, `object does not have a "missing" property`)
+
+	root.Destroy()
+	_, err = qml.Get[string](root, "label")
+	c.Assert(err, ErrorMatches, "object was destroyed")
+}
+
+func (s *S) TestGenericCallAs(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			function add(a, b) { return a + b }
+			function names() { return ["a", "b"] }
+			function fail() { throw new Error("<boom>") }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	sum, err := qml.CallAs[int](root, "add", 1, 2)
+	c.Assert(err, IsNil)
+	c.Assert(sum, Equals, 3)
+
+	names, err := qml.CallAs[[]string](root, "names")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"a", "b"})
+
+	names, err = qml.CallAs[[]string](root, "fail")
+	c.Assert(err, ErrorMatches, ".*Error: <boom>")
+	c.Assert(names, IsNil)
+
+	_, err = qml.CallAs[string](root, "names")
+	c.Assert(err, ErrorMatches, "cannot store result of method names: cannot use \\*qml.List as a string")
+}
@@ -0,0 +1,221 @@
+package qml
+
+// #include <stdlib.h>
+//
+// #include "capi.h"
+//
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// ListSource is implemented by values that provide the items presented by
+// a ListModel. Every item has the same set of roles, which QML delegates
+// see as properties with the respective names.
+type ListSource interface {
+	// Len returns the number of items in the list.
+	Len() int
+
+	// Roles returns the names of the roles available for each item.
+	// It is called once, when the model is created.
+	Roles() []string
+
+	// Field returns the value of the named role for item i.
+	Field(i int, role string) interface{}
+}
+
+// ListModel presents a list of Go values to QML views such as ListView and
+// Repeater, by implementing the protocol of Qt's QAbstractListModel. Unlike
+// a plain list, only the items actually displayed are converted, and views
+// are updated incrementally as items are inserted, removed, or changed.
+//
+// Items are inserted and removed, and the whole data replaced, by functions
+// handed to the Insert, Remove, and Reset methods, which tell views about
+// the change both before and after the function runs, as Qt requires.
+// Changes to existing items must be followed by a call to Changed. The
+// data must not be changed while QML may be reading it, which is most
+// easily achieved by changing it within RunMain.
+//
+// A ListModel is an Object, so it may be set as a context variable or
+// as a property value to be used as a model by QML views.
+type ListModel struct {
+	Common
+	source ListSource
+	roles  []string
+}
+
+// NewListModel returns a new model presenting the items of source, which
+// must be either a ListSource or a pointer to a slice. The model belongs
+// to engine, and is destroyed with it unless Destroy is called earlier.
+//
+// For slices, each item is available to delegates as modelData, and if the
+// items are structs or pointers to structs, each field visible to QML is also
+// available as a role named as the respective property would be. See the
+// package documentation for how struct fields are named.
+func NewListModel(engine *Engine, source interface{}) *ListModel {
+	listSource, ok := source.(ListSource)
+	if !ok {
+		listSource = newSliceSource(source)
+	}
+	model := &ListModel{source: listSource, roles: listSource.Roles()}
+	model.engine = engine
+	croles := make([]*C.char, len(model.roles)+1)
+	for i, role := range model.roles {
+		croles[i] = C.CString(role)
+	}
+	RunMain(func() {
+		model.addr = C.newListModel(engine.addr, &croles[0], C.int(len(model.roles)))
+		listModels[model.addr] = model
+	})
+	for _, crole := range croles[:len(model.roles)] {
+		C.free(unsafe.Pointer(crole))
+	}
+	return model
+}
+
+// listModels holds the models alive in C++, by address.
+var listModels = make(map[unsafe.Pointer]*ListModel)
+
+// Insert calls insert, which must insert n items at index i of the
+// underlying data, and notifies views about the insertion. The insert
+// function runs in the main QML thread.
+func (m *ListModel) Insert(i, n int, insert func()) {
+	ok := true
+	RunMain(func() {
+		if i < 0 || n < 1 || i > m.source.Len() {
+			ok = false
+			return
+		}
+		C.listModelBeginInsert(m.addr, C.int(i), C.int(n))
+		insert()
+		C.listModelEndInsert(m.addr)
+	})
+	if !ok {
+		panic(fmt.Sprintf("invalid list model insertion of %d items at %d", n, i))
+	}
+}
+
+// Remove calls remove, which must remove n items from index i onwards of
+// the underlying data, and notifies views about the removal. The remove
+// function runs in the main QML thread.
+func (m *ListModel) Remove(i, n int, remove func()) {
+	ok := true
+	RunMain(func() {
+		if i < 0 || n < 1 || i+n > m.source.Len() {
+			ok = false
+			return
+		}
+		C.listModelBeginRemove(m.addr, C.int(i), C.int(n))
+		remove()
+		C.listModelEndRemove(m.addr)
+	})
+	if !ok {
+		panic(fmt.Sprintf("invalid list model removal of %d items at %d", n, i))
+	}
+}
+
+// Changed notifies views that the item at index i has changed.
+func (m *ListModel) Changed(i int) {
+	ok := true
+	RunMain(func() {
+		if i < 0 || i >= m.source.Len() {
+			ok = false
+			return
+		}
+		C.listModelChanged(m.addr, C.int(i))
+	})
+	if !ok {
+		panic(fmt.Sprintf("invalid list model change of item %d", i))
+	}
+}
+
+// Reset calls reset, which may change the underlying data in ways that
+// are not conveniently described by the other methods, and has views
+// reload all the items. The reset function runs in the main QML thread.
+func (m *ListModel) Reset(reset func()) {
+	RunMain(func() {
+		C.listModelBeginReset(m.addr)
+		reset()
+		C.listModelEndReset(m.addr)
+	})
+}
+
+//export hookListModelLen
+func hookListModelLen(addr unsafe.Pointer) C.int {
+	model := listModels[addr]
+	if model == nil {
+		panic("list model used after being released")
+	}
+	return C.int(model.source.Len())
+}
+
+//export hookListModelData
+func hookListModelData(addr unsafe.Pointer, row, role C.int, resultdv *C.DataValue) {
+	model := listModels[addr]
+	if model == nil {
+		panic("list model used after being released")
+	}
+	value := model.source.Field(int(row), model.roles[role])
+	packDataValue(value, resultdv, model.engine, jsOwner)
+}
+
+//export hookListModelDestroyed
+func hookListModelDestroyed(addr unsafe.Pointer) {
+	delete(listModels, addr)
+}
+
+// sliceSource is a ListSource that presents the items of a slice.
+type sliceSource struct {
+	slicep reflect.Value
+	fields map[string]int
+	roles  []string
+}
+
+func newSliceSource(slicep interface{}) *sliceSource {
+	v := reflect.ValueOf(slicep)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("list model source must be a ListSource or a pointer to a slice, got %T", slicep))
+	}
+	s := &sliceSource{slicep: v, fields: make(map[string]int), roles: []string{"modelData"}}
+	elemt := v.Type().Elem().Elem()
+	for elemt.Kind() == reflect.Ptr {
+		elemt = elemt.Elem()
+	}
+	if elemt.Kind() == reflect.Struct {
+		for i := 0; i < elemt.NumField(); i++ {
+			name, ok := fieldName(elemt.Field(i))
+			if !ok || name == "modelData" {
+				continue
+			}
+			s.fields[name] = i
+			s.roles = append(s.roles, name)
+		}
+	}
+	return s
+}
+
+func (s *sliceSource) Len() int        { return s.slicep.Elem().Len() }
+func (s *sliceSource) Roles() []string { return s.roles }
+
+func (s *sliceSource) Field(i int, role string) interface{} {
+	slice := s.slicep.Elem()
+	if i < 0 || i >= slice.Len() {
+		return nil
+	}
+	item := slice.Index(i)
+	if role == "modelData" {
+		// Structs are handed over by reference so their fields may be changed.
+		if item.Kind() == reflect.Struct {
+			item = item.Addr()
+		}
+		return item.Interface()
+	}
+	item = deref(item)
+	if !item.IsValid() {
+		return nil
+	}
+	return item.Field(s.fields[role]).Interface()
+}
@@ -6,9 +6,12 @@ import "C"
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // SetLogger sets the target for messages logged by the qml package,
@@ -78,10 +81,68 @@ const (
 	LogWarning
 	LogCritical
 	LogFatal
+	LogInfo
 )
 
 var logHandler QmlLogger = defaultLogger{}
 
+// Warning holds the details of a warning reported by Qt or by the QML
+// engine, such as a binding loop, an attempt to access an undefined
+// property, or a console.warn call from QML code.
+type Warning struct {
+	Severity LogSeverity // LogWarning or LogCritical
+	Category string      // Logging category, such as "qml" or "default"
+	File     string
+	Line     int
+	Text     string
+}
+
+// String returns the warning in the "file:line: text" format.
+func (w *Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s", filepath.Base(w.File), w.Line, w.Text)
+}
+
+// SetWarningHandler sets a function to be called for every warning
+// reported by Qt and the QML engine, instead of sending them to the
+// logger set via SetLogger. This allows tests to fail on binding loops,
+// for example. Providing a nil handler restores the default behavior.
+//
+// The handler is called from whichever thread reported the warning,
+// which is usually but not necessarily the main GUI thread.
+func SetWarningHandler(handler func(w Warning)) {
+	warningHandler.Store(handler)
+}
+
+// warningHandler holds the func(w Warning) set via SetWarningHandler.
+// It's loaded by the log hook, which may run in any thread.
+var warningHandler atomic.Value
+
+// SetConsoleOutput sets w as the destination for the text of messages
+// logged via console.log, console.info, and console.debug from QML and
+// JavaScript code, one message per line, instead of sending them to the
+// logger set via SetLogger. This is handy for capturing debug traces in
+// tests, for example. Messages logged via console.warn and console.error
+// are warnings, and are handled as described in SetWarningHandler.
+//
+// Providing a nil writer restores the default behavior.
+//
+// Messages may be logged from several threads, but writes to w are
+// serialized.
+func SetConsoleOutput(w io.Writer) {
+	consoleOutput.Store(consoleWriter{w})
+}
+
+// consoleOutput holds the consoleWriter set via SetConsoleOutput, and
+// consoleMutex serializes the writes made to it by the log hook.
+var (
+	consoleOutput atomic.Value
+	consoleMutex  sync.Mutex
+)
+
+type consoleWriter struct {
+	w io.Writer
+}
+
 type defaultLogger struct{}
 
 func (defaultLogger) QmlOutput(msg LogMessage) error {
@@ -101,6 +162,26 @@ func hookLogHandler(cmsg *C.LogMessage) {
 	if strings.HasPrefix(text, `"Qt Warning: Compose file:`) {
 		return
 	}
+	severity := LogSeverity(cmsg.severity)
+	if handler, _ := warningHandler.Load().(func(w Warning)); handler != nil && (severity == LogWarning || severity == LogCritical) {
+		handler(Warning{
+			Severity: severity,
+			Category: C.GoStringN(cmsg.category, cmsg.categoryLen),
+			File:     C.GoStringN(cmsg.file, cmsg.fileLen),
+			Line:     int(cmsg.line),
+			Text:     C.GoStringN(cmsg.text, cmsg.textLen),
+		})
+		return
+	}
+	if console, _ := consoleOutput.Load().(consoleWriter); console.w != nil && (severity == LogDebug || severity == LogInfo) {
+		// QML files log to the "qml" category, and JavaScript files to "js".
+		if category := unsafeString(cmsg.category, cmsg.categoryLen); category == "qml" || category == "js" {
+			consoleMutex.Lock()
+			io.WriteString(console.w, text+"\n")
+			consoleMutex.Unlock()
+			return
+		}
+	}
 	msg := logMessage{c: cmsg}
 	logHandler.QmlOutput(&msg)
 	msg.invalid = true
@@ -151,7 +232,7 @@ func (m *logMessage) File() string {
 
 func (m *logMessage) Text() string {
 	m.assertValid()
-	return C.GoStringN(m.c.text, m.c.line)
+	return C.GoStringN(m.c.text, m.c.textLen)
 }
 
 func (*logMessage) privateMarker() {}
@@ -1,18 +1,26 @@
 package qml_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -22,7 +30,40 @@ import (
 	"path/filepath"
 )
 
-func init() { qml.SetupTesting() }
+// testMains holds the functions run as the main function of a child
+// test process, for testing functions that run the event loop, which
+// may only be done once per process.
+var testMains = map[string]func() error{
+	"RunContext":   runContextMain,
+	"RunOffscreen": runOffscreenMain,
+}
+
+func init() {
+	if os.Getenv("QML_TEST_MAIN") == "" {
+		qml.SetupTesting()
+	}
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv("QML_TEST_MAIN"); name != "" {
+		if err := testMains[name](); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestMain runs the named function from testMains in a child process,
+// with env appended to its environment.
+func runTestMain(c *C, name string, env ...string) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "QML_TEST_MAIN="+name, "QT_QPA_PLATFORM=offscreen")
+	cmd.Env = append(cmd.Env, env...)
+	output, err := cmd.CombinedOutput()
+	c.Assert(err, IsNil, Commentf("%s", output))
+}
 
 func Test(t *testing.T) { TestingT(t) }
 
@@ -77,6 +118,11 @@ func (s *S) TearDownTest(c *C) {
 
 type GoRect struct {
 	PaintCount int
+	Text       string
+
+	lineExtents [2]float64
+	textExtents [2]float64
+	hints       []bool
 }
 
 func (r *GoRect) Paint(p *qml.Painter) {
@@ -96,6 +142,17 @@ func (r *GoRect) Paint(p *qml.Painter) {
 	gl.Vertex2f(width, height)
 	gl.Vertex2f(0, height)
 	gl.End()
+
+	if r.Text != "" {
+		p.SetAntialiasing(true)
+		p.SetRenderHint(qml.SmoothPixmapTransform, false)
+		r.hints = []bool{p.RenderHint(qml.Antialiasing), p.RenderHint(qml.SmoothPixmapTransform)}
+
+		line := strings.Split(r.Text, "\n")[0]
+		r.lineExtents[0], r.lineExtents[1] = p.TextExtents(line)
+		r.textExtents[0], r.textExtents[1] = p.TextExtents(r.Text)
+		p.DrawText(0, 0, r.Text)
+	}
 }
 
 type GoType struct {
@@ -257,11 +314,534 @@ func (s *S) TestContextSetVars(c *C) {
 	c.Assert(s.context.Var("objectValue").(qml.Object).Int("width"), Equals, 42)
 }
 
+func (s *S) TestContextSetVarsMap(c *C) {
+	s.context.SetVar("gone", "<gone>")
+	s.context.SetVarsMap(map[string]interface{}{
+		"title": "<title>",
+		"count": 42,
+		"gone":  nil,
+	})
+
+	c.Assert(s.context.Var("title"), Equals, "<title>")
+	c.Assert(s.context.Var("count"), Equals, 42)
+	c.Assert(s.context.Var("gone"), Equals, nil)
+}
+
+func (s *S) TestContextSetVarsStruct(c *C) {
+	type Config struct {
+		Title   string
+		URLPath string
+		Width   int `qml:"windowWidth"`
+		Skipped int `qml:"-"`
+		private int
+	}
+	config := &Config{Title: "<title>", URLPath: "<path>", Width: 42, Skipped: 1, private: 2}
+	s.context.SetVarsStruct("config_", config)
+
+	c.Assert(s.context.Var("config_title"), Equals, "<title>")
+	c.Assert(s.context.Var("config_urlPath"), Equals, "<path>")
+	c.Assert(s.context.Var("config_windowWidth"), Equals, 42)
+	c.Assert(s.context.Var("config_skipped"), Equals, nil)
+	c.Assert(s.context.Var("config_private"), Equals, nil)
+
+	// Values are copied.
+	config.Title = "<changed>"
+	c.Assert(s.context.Var("config_title"), Equals, "<title>")
+
+	c.Assert(func() { s.context.SetVarsStruct("", 42) }, PanicMatches, "SetVarsStruct requires a struct or pointer to struct, got int")
+}
+
+func (s *S) TestContextVars(c *C) {
+	c.Assert(s.context.Vars(), HasLen, 0)
+
+	s.context.SetVar("b", 1)
+	s.context.SetVarsMap(map[string]interface{}{"a": 2, "c": nil})
+	s.engine.Context().SetVar("d", 3)
+
+	child := s.context.Spawn()
+	child.SetVar("e", 4)
+
+	c.Assert(s.context.Vars(), DeepEquals, []string{"a", "b", "c", "d"})
+	c.Assert(child.Vars(), DeepEquals, []string{"e"})
+}
+
+func (s *S) TestLookupProperty(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			property int i: 42
+			property real r: 4.5
+			property bool b: true
+			property string s: "<s>"
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	value, ok := root.LookupProperty("s")
+	c.Assert(value, Equals, "<s>")
+	c.Assert(ok, Equals, true)
+	value, ok = root.LookupProperty("missing")
+	c.Assert(value, IsNil)
+	c.Assert(ok, Equals, false)
+
+	i, ok := root.LookupInt("r")
+	c.Assert(i, Equals, 4)
+	c.Assert(ok, Equals, true)
+	i64, ok := root.LookupInt64("i")
+	c.Assert(i64, Equals, int64(42))
+	c.Assert(ok, Equals, true)
+	f, ok := root.LookupFloat64("i")
+	c.Assert(f, Equals, float64(42))
+	c.Assert(ok, Equals, true)
+	b, ok := root.LookupBool("b")
+	c.Assert(b, Equals, true)
+	c.Assert(ok, Equals, true)
+	str, ok := root.LookupString("s")
+	c.Assert(str, Equals, "<s>")
+	c.Assert(ok, Equals, true)
+
+	_, ok = root.LookupInt("s")
+	c.Assert(ok, Equals, false)
+	_, ok = root.LookupFloat64("missing")
+	c.Assert(ok, Equals, false)
+	_, ok = root.LookupBool("i")
+	c.Assert(ok, Equals, false)
+	_, ok = root.LookupString("b")
+	c.Assert(ok, Equals, false)
+
+	c.Assert(func() { root.Int("s") }, PanicMatches, `value of property "s" cannot be represented as an int: "<s>"`)
+	c.Assert(func() { root.Property("missing") }, PanicMatches, `object does not have a "missing" property`)
+}
+
 func (s *S) TestComponentSetDataError(c *C) {
 	_, err := s.engine.LoadString("file.qml", "Item{}")
 	c.Assert(err, ErrorMatches, "file:.*/file.qml:1 Item is not a type")
 }
 
+func (s *S) TestComponentErrorLocation(c *C) {
+	_, err := s.engine.LoadString("file.qml", "import QtQuick 2.0\nItem {\n    Foo {}\n}\n")
+	c.Assert(err, FitsTypeOf, &qml.Error{})
+	qerr := err.(*qml.Error)
+	c.Assert(qerr.URL, Matches, "file:.*/file.qml")
+	c.Assert(qerr.Line, Equals, 3)
+	c.Assert(qerr.Column, Equals, 5)
+	c.Assert(qerr.Message, Equals, "Foo is not a type")
+	c.Assert(err, ErrorMatches, "file:.*/file.qml:3 Foo is not a type")
+
+	multi := qml.MultiError{
+		{URL: "file:///a.qml", Line: 1, Column: 2, Message: "<one>"},
+		{URL: "file:///b.qml", Line: -1, Column: -1, Message: "<two>"},
+	}
+	c.Assert(multi.Error(), Equals, "file:///a.qml:1 <one>\nfile:///b.qml:-1 <two>")
+}
+
+func (s *S) TestSetWarningHandler(c *C) {
+	var warnings []qml.Warning
+	qml.SetWarningHandler(func(w qml.Warning) { warnings = append(warnings, w) })
+	defer qml.SetWarningHandler(nil)
+
+	data := "import QtQuick 2.0\nItem {\n    function warn() { console.warn('<warn>'); console.log('<log>') }\n}\n"
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	root.Call("warn")
+
+	c.Assert(warnings, HasLen, 1)
+	w := warnings[0]
+	c.Assert(w.Severity, Equals, qml.LogWarning)
+	c.Assert(w.Text, Equals, "<warn>")
+	c.Assert(w.File, Matches, "file:.*/file.qml")
+	c.Assert(w.Line, Equals, 3)
+	c.Assert(w.String(), Equals, "file.qml:3: <warn>")
+}
+
+func (s *S) TestSetConsoleOutput(c *C) {
+	var buf bytes.Buffer
+	qml.SetConsoleOutput(&buf)
+	defer qml.SetConsoleOutput(nil)
+
+	data := "import QtQuick 2.0\nItem {\n    function log() { console.log('<log>'); console.info('<info>'); console.warn('<warn>') }\n}\n"
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	root.Call("log")
+
+	c.Assert(buf.String(), Equals, "<log>\n<info>\n")
+	c.Assert(c.GetTestLog(), Matches, "(?s).*<warn>.*")
+}
+
+type ListItem struct {
+	Name  string
+	Count int
+	Notes string `qml:"-"`
+}
+
+func (s *S) TestListModel(c *C) {
+	items := []ListItem{{Name: "a", Count: 1}, {Name: "b", Count: 2}}
+	model := qml.NewListModel(s.engine, &items)
+	s.context.SetVar("listModel", model)
+
+	data := `
+		import QtQuick 2.0
+		Item {
+			property alias total: rep.count
+			Repeater {
+				id: rep
+				model: listModel
+				Item { property string text: name + count; property bool hasNotes: typeof notes != "undefined" }
+			}
+			function textAt(i) { return rep.itemAt(i).text }
+			function hasNotesAt(i) { return rep.itemAt(i).hasNotes }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	texts := func() []string {
+		var texts []string
+		for i := 0; i < root.Int("total"); i++ {
+			texts = append(texts, root.Call("textAt", i).(string))
+		}
+		return texts
+	}
+
+	c.Assert(texts(), DeepEquals, []string{"a1", "b2"})
+	c.Assert(root.Call("hasNotesAt", 0), Equals, false)
+
+	model.Insert(1, 1, func() {
+		c.Check(root.Int("total"), Equals, 2)
+		items = append(items[:1], append([]ListItem{{Name: "x", Count: 9}}, items[1:]...)...)
+	})
+	c.Assert(texts(), DeepEquals, []string{"a1", "x9", "b2"})
+
+	qml.RunMain(func() { items[2].Count = 3 })
+	model.Changed(2)
+	c.Assert(texts(), DeepEquals, []string{"a1", "x9", "b3"})
+
+	model.Remove(0, 1, func() {
+		c.Check(root.Int("total"), Equals, 3)
+		items = items[1:]
+	})
+	c.Assert(texts(), DeepEquals, []string{"x9", "b3"})
+
+	model.Reset(func() { items = []ListItem{{Name: "z", Count: 0}} })
+	c.Assert(texts(), DeepEquals, []string{"z0"})
+
+	c.Assert(func() { model.Changed(1) }, Panics, "invalid list model change of item 1")
+	c.Assert(func() { model.Insert(2, 1, func() {}) }, Panics, "invalid list model insertion of 1 items at 2")
+	c.Assert(func() { model.Remove(0, 2, func() {}) }, Panics, "invalid list model removal of 2 items at 0")
+
+	c.Assert(func() { qml.NewListModel(s.engine, items) }, Panics,
+		"list model source must be a ListSource or a pointer to a slice, got []qml_test.ListItem")
+}
+
+func (s *S) TestDestroyTwice(c *C) {
+	component, err := s.engine.LoadString("file.qml", "import QtQuick 2.0\nItem { property int n: 42; signal done }")
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	c.Assert(root.Int("n"), Equals, 42)
+
+	root.Destroy()
+	root.Destroy()
+
+	c.Assert(func() { root.Property("n") }, Panics, "object was destroyed")
+	c.Assert(func() { root.Set("n", 1) }, Panics, "object was destroyed")
+	c.Assert(func() { root.Call("toString") }, Panics, "object was destroyed")
+	c.Assert(root.CallAs("toString", nil), ErrorMatches, "object was destroyed")
+	c.Assert(root.Emit("done"), ErrorMatches, "object was destroyed")
+	_, err = root.OnConnection("done", func() {})
+	c.Assert(err, ErrorMatches, "object was destroyed")
+	_, ok := root.GoValue()
+	c.Assert(ok, Equals, false)
+
+	win := component.CreateWindow(nil)
+	win.Destroy()
+	c.Assert(func() { win.Show() }, Panics, "object was destroyed")
+	c.Assert(func() { win.Hide() }, Panics, "object was destroyed")
+	c.Assert(func() { win.Root() }, Panics, "object was destroyed")
+	c.Assert(func() { win.Position() }, Panics, "object was destroyed")
+	c.Assert(func() { win.SetGeometry(0, 0, 10, 10) }, Panics, "object was destroyed")
+	c.Assert(func() { win.OnClose(nil) }, Panics, "object was destroyed")
+	c.Assert(func() { win.Wait() }, Panics, "object was destroyed")
+	c.Assert(win.SetIconFile("icon.png"), ErrorMatches, "object was destroyed")
+	c.Assert(win.WaitContext(context.Background()), ErrorMatches, "object was destroyed")
+	_, err = win.Grab()
+	c.Assert(err, ErrorMatches, "object was destroyed")
+}
+
+func (s *S) TestDestroyedElsewhere(c *C) {
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		Item {
+			Item { objectName: "first" }
+			Item { id: second; objectName: "second" }
+			function destroySecond() { second.destroy() }
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	// All values representing an object observe its destruction.
+	first := root.ObjectByName("first")
+	again := root.ObjectByName("first")
+	first.Destroy()
+	c.Assert(again.CallAs("toString", nil), ErrorMatches, "object was destroyed")
+	c.Assert(func() { again.Property("objectName") }, Panics, "object was destroyed")
+
+	// Including when it is destroyed by QML logic.
+	second := root.ObjectByName("second")
+	root.Call("destroySecond")
+	for i := 0; second.CallAs("toString", nil) == nil; i++ {
+		if i == 100 {
+			c.Fatalf("object destroyed by QML is still reported as alive")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(func() { second.Property("objectName") }, Panics, "object was destroyed")
+}
+
+func (s *S) TestContextSetFunc(c *C) {
+	s.context.SetFunc("formatCurrency", func(v float64) string {
+		return fmt.Sprintf("$%.2f", v)
+	})
+	s.context.SetFunc("sum", func(prefix string, values ...int) string {
+		total := 0
+		for _, v := range values {
+			total += v
+		}
+		return fmt.Sprintf("%s%d", prefix, total)
+	})
+	s.context.SetFunc("check", func(ok bool) (int, error) {
+		if !ok {
+			return 0, fmt.Errorf("<failure>")
+		}
+		return 42, nil
+	})
+
+	data := `
+		import QtQuick 2.0
+		Item {
+			property string currency: formatCurrency(1234.5)
+			property string total: sum("total: ", 1, 2, 3)
+			property string empty: sum("none: ")
+			property int checked: check(true)
+			property string failure: {
+				try {
+					check(false)
+					return "no exception"
+				} catch (e) {
+					return e.message
+				}
+			}
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+
+	c.Assert(obj.String("currency"), Equals, "$1234.50")
+	c.Assert(obj.String("total"), Equals, "total: 6")
+	c.Assert(obj.String("empty"), Equals, "none: 0")
+	c.Assert(obj.Int("checked"), Equals, 42)
+	c.Assert(obj.String("failure"), Equals, "<failure>")
+}
+
+func (s *S) TestSettings(c *C) {
+	os.Setenv("XDG_CONFIG_HOME", c.MkDir())
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	settings := qml.Settings("go-qml", "test")
+	defer settings.Remove("test")
+
+	settings.SetValue("test/string", "<string>")
+	settings.SetValue("test/bool", true)
+	settings.SetValue("test/int", 42)
+	settings.SetValue("test/float", 1.5)
+	settings.SetValue("test/strings", []string{"a", "b"})
+	c.Assert(settings.Sync(), IsNil)
+
+	settings = qml.Settings("go-qml", "test")
+	c.Assert(settings.Value("test/string", ""), Equals, "<string>")
+	c.Assert(settings.Value("test/bool", false), Equals, true)
+	c.Assert(settings.Value("test/int", 0), Equals, 42)
+	c.Assert(settings.Value("test/float", 0.0), Equals, 1.5)
+	c.Assert(settings.Value("test/strings", []string(nil)), DeepEquals, []string{"a", "b"})
+	c.Assert(settings.Value("test/missing", 7), Equals, 7)
+	c.Assert(settings.Value("test/missing", nil), IsNil)
+	c.Assert(settings.Value("test/string", nil), Equals, "<string>")
+
+	settings.Remove("test/int")
+	c.Assert(settings.Value("test/int", 0), Equals, 0)
+
+	c.Assert(settings.SetValue("test/struct", struct{ A int }{}), ErrorMatches, `unsupported setting value type: struct \{ A int \}`)
+	c.Assert(settings.SetValue("test/pointer", &GoType{}), ErrorMatches, `unsupported setting value type: \*qml_test.GoType`)
+	c.Assert(func() { settings.Value("test/string", &GoType{}) }, PanicMatches, `unsupported setting value type: \*qml_test.GoType`)
+}
+
+func (s *S) TestClipboard(c *C) {
+	var changes, disconnected int
+	cb := qml.Clipboard()
+	conn := cb.OnChange(func() { changes++ })
+	defer conn.Disconnect()
+	var once *qml.Connection
+	once = cb.OnChange(func() {
+		disconnected++
+		once.Disconnect()
+	})
+	cb.SetText("<text>")
+	c.Assert(cb.Text(), Equals, "<text>")
+	cb.SetText("<other>")
+	qml.RunMain(func() {
+		c.Assert(changes > 1, Equals, true)
+		c.Assert(disconnected, Equals, 1)
+	})
+}
+
+func (s *S) TestAddApplicationFont(c *C) {
+	_, err := qml.AddApplicationFont([]byte("<not a font>"))
+	c.Assert(err, ErrorMatches, "cannot add application font: unsupported font data")
+	_, err = qml.AddApplicationFontFile("qrc:///missing.ttf")
+	c.Assert(err, ErrorMatches, "cannot add application font from qrc:///missing.ttf")
+
+	fonts, _ := filepath.Glob("/usr/share/fonts/*/*/*.ttf")
+	if len(fonts) == 0 {
+		c.Skip("no TrueType fonts found in /usr/share/fonts")
+	}
+	data, err := ioutil.ReadFile(fonts[0])
+	c.Assert(err, IsNil)
+
+	family, err := qml.AddApplicationFont(data)
+	c.Assert(err, IsNil)
+	c.Assert(family, Not(Equals), "")
+
+	var rp qml.ResourcesPacker
+	rp.Add("fonts/test.ttf", data)
+	r := rp.Pack()
+	qml.LoadResources(r)
+	defer qml.UnloadResources(r)
+	family2, err := qml.AddApplicationFontFile("qrc:///fonts/test.ttf")
+	c.Assert(err, IsNil)
+	c.Assert(family2, Equals, family)
+
+	s.context.SetVar("fontFamily", family)
+	component, err := s.engine.LoadString("file.qml", `import QtQuick 2.0; Text { font.family: fontFamily; property string f: font.family }`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.String("f"), Equals, family)
+}
+
+func (s *S) TestLocalizedResources(c *C) {
+	var rp qml.ResourcesPacker
+	rp.AddString("i18n/Greeting.qml", "import QtQuick 2.0\nItem { property string text: 'Hello' }")
+	rp.AddString("i18n/+pt_BR/Greeting.qml", "import QtQuick 2.0\nItem { property string text: 'Olá' }")
+	rp.AddString("i18n/+de/Greeting.qml", "import QtQuick 2.0\nItem { property string text: 'Hallo' }")
+	r := rp.Pack()
+	qml.LoadResources(r)
+	defer qml.UnloadResources(r)
+
+	greeting := func(locale string) string {
+		engine := qml.NewEngine()
+		defer engine.Destroy()
+		engine.SetUILanguage(locale)
+		component, err := engine.LoadFile("qrc:///i18n/Greeting.qml")
+		c.Assert(err, IsNil)
+		root := component.Create(nil)
+		defer root.Destroy()
+		return root.String("text")
+	}
+
+	c.Assert(greeting("C"), Equals, "Hello")
+	c.Assert(greeting("pt_BR"), Equals, "Olá")
+	c.Assert(greeting("de_AT"), Equals, "Hallo")
+}
+
+func (s *S) TestScreens(c *C) {
+	screens := qml.Screens()
+	c.Assert(len(screens) > 0, Equals, true)
+	for _, screen := range screens {
+		_, _, width, height := screen.Geometry()
+		c.Check(width > 0 && height > 0, Equals, true)
+		_, _, awidth, aheight := screen.AvailableGeometry()
+		c.Check(awidth > 0 && awidth <= width, Equals, true)
+		c.Check(aheight > 0 && aheight <= height, Equals, true)
+		c.Check(screen.DevicePixelRatio() >= 1, Equals, true)
+	}
+	conn := qml.OnScreensChange(func() {})
+	c.Assert(conn, NotNil)
+	conn.Disconnect()
+	conn.Disconnect()
+}
+
+func (s *S) TestTranslation(c *C) {
+	err := s.engine.LoadTranslation("missing.qm")
+	c.Assert(err, ErrorMatches, "cannot load translation from missing.qm")
+
+	component, err := s.engine.LoadString("file.qml", "import QtQuick 2.0\nItem { property string locale: Qt.locale().name; property string text: qsTr('Hello') }")
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	s.engine.SetUILanguage("pt_BR")
+	defer s.engine.SetUILanguage("C")
+	c.Assert(root.String("text"), Equals, "Hello")
+	c.Assert(root.String("locale"), Equals, "pt_BR")
+}
+
+func (s *S) TestActiveFocus(c *C) {
+	data := `
+		import QtQuick 2.0
+		Column {
+			width: 300; height: 200
+			TextInput { objectName: "name"; width: 300 }
+			TextInput { objectName: "email"; width: 300 }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+
+	window := component.CreateWindow(nil)
+	defer window.Destroy()
+	window.Show()
+	defer window.Hide()
+
+	root := window.Root()
+	name := root.ObjectByName("name")
+	email := root.ObjectByName("email")
+
+	waitFocus := func(item qml.Object) {
+		for retries := 30; ; retries-- {
+			if focus := window.ActiveFocusItem(); focus != nil && focus.Addr() == item.Addr() {
+				break
+			}
+			if retries == 0 {
+				c.Fatalf("item did not get active focus")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	name.ForceActiveFocus()
+	waitFocus(name)
+	c.Assert(name.Bool("activeFocus"), Equals, true)
+
+	email.ForceActiveFocus()
+	waitFocus(email)
+	c.Assert(name.Bool("activeFocus"), Equals, false)
+	c.Assert(email.Bool("activeFocus"), Equals, true)
+
+	c.Assert(func() { component.ForceActiveFocus() }, Panics, "object is not a visual item")
+}
+
 func (s *S) TestComponentCreateWindow(c *C) {
 	data := `
 		import QtQuick 2.0
@@ -303,6 +883,122 @@ func (s *S) TestContextSpawn(c *C) {
 	c.Assert(obj2.String("s"), Equals, "context2")
 }
 
+func (s *S) TestContextSpawnFallThrough(c *C) {
+	root := s.engine.Context()
+	root.SetVar("theme", "light")
+	root.SetVar("title", "<title>")
+
+	child := root.Spawn()
+	child.SetVar("theme", "dark")
+
+	data := `
+		import QtQuick 2.0
+		Item { property var t1: theme; property var t2: title }
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+
+	obj1 := component.Create(root)
+	defer obj1.Destroy()
+	obj2 := component.Create(child)
+	defer obj2.Destroy()
+
+	c.Assert(obj1.String("t1"), Equals, "light")
+	c.Assert(obj2.String("t1"), Equals, "dark")
+	c.Assert(obj2.String("t2"), Equals, "<title>")
+	c.Assert(root.Var("theme"), Equals, "light")
+}
+
+type signalReceiver struct {
+	clicks int
+	moves  [][2]int
+	urls   int
+}
+
+func (r *signalReceiver) OnClicked()       { r.clicks++ }
+func (r *signalReceiver) OnMoved(x, y int) { r.moves = append(r.moves, [2]int{x, y}) }
+func (r *signalReceiver) OnURLChanged()    { r.urls++ }
+func (r *signalReceiver) OnMissing()       { panic("OnMissing must not be connected") }
+func (r *signalReceiver) Clicked()         { panic("Clicked must not be connected") }
+
+type badSignalReceiver struct{}
+
+func (r *badSignalReceiver) OnClicked()     {}
+func (r *badSignalReceiver) OnMoved(x bool) {}
+
+func (s *S) TestConnectReceiver(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			signal clicked
+			signal moved(int x, int y)
+			signal urlChanged
+			function emitAll() { clicked(); moved(1, 2); clicked(); urlChanged() }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	recv := &signalReceiver{}
+	conns, err := root.ConnectReceiver(recv)
+	c.Assert(err, IsNil)
+	c.Assert(conns, HasLen, 3)
+	root.Call("emitAll")
+	c.Assert(recv.clicks, Equals, 2)
+	c.Assert(recv.moves, DeepEquals, [][2]int{{1, 2}})
+	c.Assert(recv.urls, Equals, 1)
+
+	bad, err := root.ConnectReceiver(&badSignalReceiver{})
+	c.Assert(err, ErrorMatches, `cannot connect \*qml_test.badSignalReceiver.OnMoved: signal "moved" parameter 0 is a int; cannot convert it to bool`)
+	c.Assert(bad, IsNil)
+	root.Call("emitAll")
+	c.Assert(recv.clicks, Equals, 4)
+
+	for _, conn := range conns {
+		conn.Disconnect()
+	}
+	root.Call("emitAll")
+	c.Assert(recv.clicks, Equals, 4)
+	c.Assert(recv.urls, Equals, 2)
+}
+
+func (s *S) TestContextDestroy(c *C) {
+	alive := qml.Stats().ContextsAlive
+
+	values := qml.Stats().ValuesAlive
+
+	child := s.context.Spawn()
+	child.SetVar("value", &GoType{StringValue: "<value>"})
+	child.SetFunc("double", func(n int) int { return n * 2 })
+	c.Assert(child.Vars(), DeepEquals, []string{"double", "value"})
+	c.Assert(qml.Stats().ContextsAlive, Equals, alive+1)
+
+	child.Destroy()
+	child.Destroy()
+
+	// The context and the function wrapper are both released, while the
+	// value stays with the engine, which may hand it to other contexts.
+	for retries := 30; qml.Stats().ContextsAlive != alive || qml.Stats().ValuesAlive > values+1; retries-- {
+		if retries == 0 {
+			c.Fatalf("context was not released after Destroy")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	c.Assert(func() { s.context.Destroy() }, Panics, "cannot destroy the engine's root context")
+	s.context.SetVar("value", "<root>")
+	c.Assert(s.context.Var("value"), Equals, "<root>")
+}
+
+func (s *S) TestOnChangeNotNotifiable(c *C) {
+	obj := cpptest.NewTestType(s.engine)
+	conn, err := obj.OnChange("voidAddr", func() {})
+	c.Assert(err, ErrorMatches, `property "voidAddr" does not notify changes`)
+	c.Assert(conn, IsNil)
+}
+
 func (s *S) TestReadVoidAddrProperty(c *C) {
 	obj := cpptest.NewTestType(s.engine)
 	addr := obj.Property("voidAddr").(uintptr)
@@ -365,17 +1061,214 @@ func (s *S) TestIssue84(c *C) {
 	c.Assert(root.String("s1"), Equals, "<after>")
 }
 
-func (s *S) TestResources(c *C) {
-	var rp qml.ResourcesPacker
-	rp.Add("sub/path/Foo.qml", []byte("import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Foo>') }"))
-	rp.AddString("sub/path/Bar.qml", "import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Bar>') }")
-	rp.AddString("/sub/Main.qml", "import QtQuick 2.0\nimport \"./path\"\nItem {\nFoo{}\nBar{}\n}")
+func (s *S) TestLoadReader(c *C) {
+	dir := c.MkDir()
+	err := os.Mkdir(filepath.Join(dir, "controls"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "controls", "Label.qml"), []byte("import QtQuick 2.0\nItem { property string text: '<label>' }"), 0644)
+	c.Assert(err, IsNil)
 
-	r := rp.Pack()
-	qml.LoadResources(r)
-	testResourcesLoaded(c, true)
-	qml.UnloadResources(r)
-	testResourcesLoaded(c, false)
+	r := strings.NewReader(`
+		import QtQuick 2.0
+		import "./controls"
+		Label {}
+	`)
+	component, err := s.engine.Load(filepath.Join(dir, "remote.qml"), r)
+	c.Assert(err, IsNil)
+
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("text"), Equals, "<label>")
+
+	_, err = s.engine.Load("file.qml", iotest.TimeoutReader(strings.NewReader("Item {}")))
+	c.Assert(err, Equals, iotest.ErrTimeout)
+}
+
+func (s *S) TestSetBaseURL(c *C) {
+	var rp qml.ResourcesPacker
+	rp.AddString("ui/controls/Label.qml", "import QtQuick 2.0\nItem { property string text: '<label>' }")
+	r := rp.Pack()
+	qml.LoadResources(r)
+	defer qml.UnloadResources(r)
+
+	c.Assert(s.engine.BaseURL(), Equals, "")
+	err := s.engine.SetBaseURL("qrc:///ui/")
+	c.Assert(err, IsNil)
+	c.Assert(s.engine.BaseURL(), Equals, "qrc:///ui/")
+
+	component, err := s.engine.LoadString("snippet.qml", "import QtQuick 2.0\nimport \"controls\"\nLabel {}")
+	c.Assert(err, IsNil)
+
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("text"), Equals, "<label>")
+
+	err = s.engine.SetBaseURL("ftp://example.com/")
+	c.Assert(err, ErrorMatches, `invalid base URL "ftp://example.com/": scheme must be qrc, file, http, or https`)
+	err = s.engine.SetBaseURL("some/dir/")
+	c.Assert(err, ErrorMatches, `invalid base URL "some/dir/": .*`)
+	c.Assert(s.engine.BaseURL(), Equals, "qrc:///ui/")
+}
+
+func (s *S) TestOfflineStoragePath(c *C) {
+	dir := filepath.Join(c.MkDir(), "storage", "sub")
+	err := s.engine.SetOfflineStoragePath(dir)
+	c.Assert(err, IsNil)
+	c.Assert(s.engine.OfflineStoragePath(), Equals, dir)
+
+	info, err := os.Stat(dir)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsDir(), Equals, true)
+
+	file := filepath.Join(c.MkDir(), "file")
+	err = ioutil.WriteFile(file, nil, 0644)
+	c.Assert(err, IsNil)
+	err = s.engine.SetOfflineStoragePath(filepath.Join(file, "sub"))
+	c.Assert(err, NotNil)
+	c.Assert(s.engine.OfflineStoragePath(), Equals, dir)
+}
+
+func (s *S) TestAddImportPath(c *C) {
+	dir := c.MkDir()
+	moddir := filepath.Join(dir, "MyCompany", "Controls")
+	err := os.MkdirAll(moddir, 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(moddir, "qmldir"), []byte("module MyCompany.Controls\nLabel 1.0 Label.qml\n"), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(moddir, "Label.qml"), []byte("import QtQuick 2.0\nItem { property string text: '<disk>' }"), 0644)
+	c.Assert(err, IsNil)
+
+	var rp qml.ResourcesPacker
+	rp.AddString("imports/MyCompany/Bundled/qmldir", "module MyCompany.Bundled\nLabel 1.0 Label.qml\n")
+	rp.AddString("imports/MyCompany/Bundled/Label.qml", "import QtQuick 2.0\nItem { property string text: '<qrc>' }")
+	r := rp.Pack()
+	qml.LoadResources(r)
+	defer qml.UnloadResources(r)
+
+	data := `
+		import QtQuick 2.0
+		import MyCompany.Controls 1.0 as Disk
+		import MyCompany.Bundled 1.0 as Qrc
+		Item {
+			property string disk: d.text
+			property string qrc: q.text
+			Disk.Label { id: d }
+			Qrc.Label { id: q }
+		}
+	`
+	s.engine.AddImportPath(dir)
+	s.engine.AddImportPath("qrc:///imports")
+
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("disk"), Equals, "<disk>")
+	c.Assert(root.String("qrc"), Equals, "<qrc>")
+}
+
+func (s *S) TestAddPluginPath(c *C) {
+	dir, err := filepath.EvalSymlinks(c.MkDir())
+	c.Assert(err, IsNil)
+
+	before := s.engine.PluginPaths()
+	s.engine.AddPluginPath(dir)
+
+	paths := s.engine.PluginPaths()
+	c.Assert(paths, HasLen, len(before)+1)
+	c.Assert(paths[0], Equals, dir)
+}
+
+func (s *S) TestClearComponentCache(c *C) {
+	dir := c.MkDir()
+	filename := filepath.Join(dir, "Sub.qml")
+	writeSub := func(text string) {
+		err := ioutil.WriteFile(filename, []byte("import QtQuick 2.0\nItem { property string text: '"+text+"' }"), 0644)
+		c.Assert(err, IsNil)
+	}
+	create := func() qml.Object {
+		component, err := s.engine.LoadString(filepath.Join(dir, "main.qml"), "import QtQuick 2.0\nSub {}")
+		c.Assert(err, IsNil)
+		return component.Create(nil)
+	}
+
+	writeSub("<before>")
+	root1 := create()
+	defer root1.Destroy()
+	c.Assert(root1.String("text"), Equals, "<before>")
+
+	writeSub("<after>")
+	s.engine.ClearComponentCache()
+	root2 := create()
+	defer root2.Destroy()
+	c.Assert(root2.String("text"), Equals, "<after>")
+	c.Assert(root1.String("text"), Equals, "<before>")
+}
+
+func (s *S) TestPreload(c *C) {
+	dir := c.MkDir()
+	write := func(name, content string) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		c.Assert(err, IsNil)
+	}
+	write("Sub.qml", "import QtQuick 2.0\nItem { property string text: '<sub>' }")
+	write("Main.qml", "import QtQuick 2.0\nSub {}")
+	write("Broken.qml", "import QtQuick 2.0\nItem { broken: 1 }")
+
+	err := s.engine.Preload(filepath.Join(dir, "Main.qml"), filepath.Join(dir, "Sub.qml"))
+	c.Assert(err, IsNil)
+
+	component, err := s.engine.LoadFile(filepath.Join(dir, "Main.qml"))
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.String("text"), Equals, "<sub>")
+
+	err = s.engine.Preload(filepath.Join(dir, "Broken.qml"))
+	c.Assert(err, FitsTypeOf, &qml.Error{})
+	c.Assert(err.(*qml.Error).URL, Matches, ".*/Broken.qml")
+	c.Assert(err.(*qml.Error).Line, Equals, 2)
+
+	err = s.engine.Preload(filepath.Join(dir, "Main.qml"), filepath.Join(dir, "Broken.qml"), filepath.Join(dir, "Missing.qml"))
+	c.Assert(err, FitsTypeOf, qml.MultiError{})
+	errs := err.(qml.MultiError)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs[0].URL, Matches, ".*/Broken.qml")
+	c.Assert(errs[1].URL, Matches, ".*/Missing.qml")
+}
+
+func (s *S) TestPixmapCache(c *C) {
+	defer s.engine.SetPixmapCacheLimit(10240)
+	s.engine.SetPixmapCacheLimit(512)
+	s.engine.ClearPixmapCache()
+	c.Assert(func() { s.engine.SetPixmapCacheLimit(-1) }, Panics, "invalid pixmap cache limit: -1")
+
+	// Images still load after the caches are cleared.
+	s.engine.AddImageProvider("cached", func(id string, width, height int) image.Image {
+		return image.NewRGBA(image.Rect(0, 0, 4, 4))
+	})
+	component, err := s.engine.LoadString("file.qml", `import QtQuick 2.0; Image { source: "image://cached/a" }`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.Int("width"), Equals, 4)
+}
+
+func (s *S) TestResources(c *C) {
+	var rp qml.ResourcesPacker
+	rp.Add("sub/path/Foo.qml", []byte("import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Foo>') }"))
+	rp.AddString("sub/path/Bar.qml", "import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Bar>') }")
+	rp.AddString("/sub/Main.qml", "import QtQuick 2.0\nimport \"./path\"\nItem {\nFoo{}\nBar{}\n}")
+
+	r := rp.Pack()
+	qml.LoadResources(r)
+	testResourcesLoaded(c, true)
+	qml.UnloadResources(r)
+	testResourcesLoaded(c, false)
 
 	data := r.Bytes()
 
@@ -394,6 +1287,287 @@ func (s *S) TestResources(c *C) {
 	testResourcesLoaded(c, false)
 }
 
+func (s *S) TestResourcesCompressed(c *C) {
+	padding := strings.Repeat("// Padding so the content is worth compressing.\n", 100)
+
+	var plain, compressed qml.ResourcesPacker
+	compressed.SetCompression(9)
+	for _, rp := range []*qml.ResourcesPacker{&plain, &compressed} {
+		rp.AddString("sub/path/Foo.qml", "import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Foo>') }\n"+padding)
+		rp.AddString("sub/path/Bar.qml", "import QtQuick 2.0\nItem { Component.onCompleted: console.log('<Bar>') }\n"+padding)
+		rp.AddString("/sub/Main.qml", "import QtQuick 2.0\nimport \"./path\"\nItem {\nFoo{}\nBar{}\n}")
+	}
+
+	r := compressed.Pack()
+	c.Assert(len(r.Bytes()) < len(plain.Pack().Bytes())/2, Equals, true)
+
+	qml.LoadResources(r)
+	testResourcesLoaded(c, true)
+	qml.UnloadResources(r)
+	testResourcesLoaded(c, false)
+
+	c.Assert(func() { compressed.SetCompression(42) }, PanicMatches, "invalid resources compression level: 42")
+}
+
+func (s *S) TestResourcesAddWithAlias(c *C) {
+	var rp qml.ResourcesPacker
+	err := rp.AddWithAlias("images/logo.png", "assets/logo.png", []byte("<logo>"))
+	c.Assert(err, IsNil)
+	err = rp.AddWithAlias("icons/logo.png", "/assets/logo.png", []byte("<other>"))
+	c.Assert(err, ErrorMatches, "cannot add icons/logo.png as /assets/logo.png: already used by images/logo.png")
+
+	rp.Add("sub/Main.qml", []byte("import QtQuick 2.0\nItem {}"))
+	err = rp.AddWithAlias("Main.qml", "sub/Main.qml", []byte("import QtQuick 2.0\nItem {}"))
+	c.Assert(err, ErrorMatches, "cannot add Main.qml as sub/Main.qml: path already in use")
+	err = rp.AddWithAlias("other", "sub", []byte("<data>"))
+	c.Assert(err, ErrorMatches, "cannot add other as sub: path already in use")
+
+	err = rp.AddWithAlias("ui/Main.qml", "app/Main.qml", []byte("import QtQuick 2.0\nItem { width: 42 }"))
+	c.Assert(err, IsNil)
+
+	r := rp.Pack()
+	qml.LoadResources(r)
+	defer qml.UnloadResources(r)
+
+	component, err := s.engine.LoadFile("qrc:///app/Main.qml")
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.Int("width"), Equals, 42)
+}
+
+func (s *S) TestResourcesDeterministic(c *C) {
+	files := []struct{ path, data string }{
+		{"sub/path/Foo.qml", "<Foo>"},
+		{"sub/path/Bar.qml", "<Bar>"},
+		{"sub/Main.qml", "<Main>"},
+		{"other/Main.qml", "<Other>"},
+	}
+	var rp1, rp2 qml.ResourcesPacker
+	for i := range files {
+		rp1.AddString(files[i].path, files[i].data)
+		rp2.AddString(files[len(files)-1-i].path, files[len(files)-1-i].data)
+	}
+	c.Assert(rp1.Pack().Bytes(), DeepEquals, rp2.Pack().Bytes())
+	c.Assert(rp1.Pack().Bytes(), DeepEquals, rp1.Pack().Bytes())
+}
+
+func (s *S) TestResourcesList(c *C) {
+	big := strings.Repeat("<data>", 100)
+	for _, level := range []int{0, 9} {
+		var rp qml.ResourcesPacker
+		rp.SetCompression(level)
+		rp.AddString("sub/path/Foo.qml", big)
+		rp.AddString("/sub/Main.qml", "<Main>")
+		rp.AddString("Top.qml", "<Top>")
+		rp.AddString("sub/Ünicode.qml", "<Ünicode>")
+
+		want := []qml.ResourceInfo{
+			{Path: "Top.qml", Size: 5},
+			{Path: "sub/Main.qml", Size: 6},
+			{Path: "sub/path/Foo.qml", Size: len(big)},
+			{Path: "sub/Ünicode.qml", Size: 10},
+		}
+		r := rp.Pack()
+		c.Assert(r.List(), DeepEquals, want)
+
+		rs, err := qml.ParseResourcesString(string(r.Bytes()))
+		c.Assert(err, IsNil)
+		c.Assert(rs.List(), DeepEquals, want)
+	}
+}
+
+func (s *S) TestResourcesContains(c *C) {
+	var rp qml.ResourcesPacker
+	rp.AddString("themes/dark/Theme.qml", "<Dark>")
+	rp.AddString("Top.qml", "<Top>")
+	r := rp.Pack()
+
+	c.Assert(r.Contains("Top.qml"), Equals, true)
+	c.Assert(r.Contains("/Top.qml"), Equals, true)
+	c.Assert(r.Contains("themes/dark/Theme.qml"), Equals, true)
+	c.Assert(r.Contains("qrc:///themes/dark/Theme.qml"), Equals, true)
+	c.Assert(r.Contains("qrc:/themes/dark/../dark/Theme.qml"), Equals, true)
+	c.Assert(r.Contains("themes/dark"), Equals, false)
+	c.Assert(r.Contains("themes/light/Theme.qml"), Equals, false)
+	c.Assert(r.Contains("Top.qml/Other.qml"), Equals, false)
+	c.Assert(r.Contains(""), Equals, false)
+}
+
+func (s *S) TestResourcesReadFile(c *C) {
+	big := strings.Repeat("<data>", 100)
+	for _, level := range []int{0, 9} {
+		var rp qml.ResourcesPacker
+		rp.SetCompression(level)
+		rp.AddString("config/defaults.json", big)
+		rp.AddString("Top.qml", "<Top>")
+
+		r, err := qml.ParseResourcesString(string(rp.Pack().Bytes()))
+		c.Assert(err, IsNil)
+
+		data, err := r.ReadFile("config/defaults.json")
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, big)
+
+		data, err = r.ReadFile("qrc:///Top.qml")
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, "<Top>")
+
+		_, err = r.ReadFile("config")
+		c.Assert(os.IsNotExist(err), Equals, true)
+		_, err = r.ReadFile("missing.json")
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+func (s *S) TestResourcesWriteTo(c *C) {
+	big := strings.Repeat("<data>", 100)
+	for _, level := range []int{0, 9} {
+		var rp qml.ResourcesPacker
+		rp.SetCompression(level)
+		rp.AddString("sub/Big.qml", big)
+		rp.AddString("Top.qml", "<Top>")
+
+		var buf bytes.Buffer
+		n, err := rp.WriteTo(&buf)
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, int64(buf.Len()))
+		c.Assert(buf.Bytes(), DeepEquals, rp.Pack().Bytes())
+
+		r, err := qml.ParseResources(buf.Bytes())
+		c.Assert(err, IsNil)
+		data, err := r.ReadFile("sub/Big.qml")
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, big)
+	}
+}
+
+func (s *S) TestResourcesAddReader(c *C) {
+	big := strings.Repeat("<data>", 100)
+	for _, level := range []int{0, 9} {
+		var rp qml.ResourcesPacker
+		rp.SetCompression(level)
+		seeker := strings.NewReader("<skip>" + big)
+		seeker.Seek(6, io.SeekStart)
+		err := rp.AddReader("sub/Big.qml", seeker)
+		c.Assert(err, IsNil)
+		err = rp.AddReader("sub/Foo.qml", iotest.OneByteReader(strings.NewReader("<Foo>")))
+		c.Assert(err, IsNil)
+		err = rp.AddReader("sub/Foo.qml", strings.NewReader("<Again>"))
+		c.Assert(err, ErrorMatches, "cannot add resource sub/Foo.qml: path already in use")
+
+		// Both readers are read again, or their data reused, on every pack.
+		var buf bytes.Buffer
+		_, err = rp.WriteTo(&buf)
+		c.Assert(err, IsNil)
+		r := rp.Pack()
+		c.Assert(r.Bytes(), DeepEquals, buf.Bytes())
+
+		c.Assert(r.List(), DeepEquals, []qml.ResourceInfo{{Path: "sub/Big.qml", Size: len(big)}, {Path: "sub/Foo.qml", Size: 5}})
+		data, err := r.ReadFile("sub/Big.qml")
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, big)
+		data, err = r.ReadFile("sub/Foo.qml")
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, "<Foo>")
+	}
+
+	var rp qml.ResourcesPacker
+	err := rp.AddReader("Bad.qml", iotest.TimeoutReader(strings.NewReader("<Bad>")))
+	c.Assert(err, IsNil)
+	_, err = rp.WriteTo(ioutil.Discard)
+	c.Assert(err, ErrorMatches, "cannot read resource Bad.qml: timeout")
+}
+
+func (s *S) TestResourcesHash(c *C) {
+	big := strings.Repeat("<data>", 100)
+	hashes := make(map[string]bool)
+	for _, level := range []int{0, 9} {
+		var rp qml.ResourcesPacker
+		rp.SetCompression(level)
+		rp.AddString("sub/Big.qml", big)
+		rp.AddString("Top.qml", "<Top>")
+		hashes[rp.Pack().Hash()] = true
+	}
+	c.Assert(hashes, HasLen, 1)
+
+	var rp qml.ResourcesPacker
+	rp.AddString("sub/Big.qml", big)
+	rp.AddString("Top.qml", "<Other>")
+	c.Assert(hashes[rp.Pack().Hash()], Equals, false)
+
+	var empty qml.ResourcesPacker
+	c.Assert(empty.Pack().Hash(), Equals, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+
+	// The hash is stored with the packed data, and computed on demand
+	// for data packed without it, by dropping the block holding it.
+	for hash := range hashes {
+		var rp qml.ResourcesPacker
+		rp.AddString("sub/Big.qml", big)
+		rp.AddString("Top.qml", "<Top>")
+		data := rp.Pack().Bytes()
+
+		r, err := qml.ParseResourcesString(string(data))
+		c.Assert(err, IsNil)
+		c.Assert(r.Hash(), Equals, hash)
+
+		const extSize = 40
+		legacy := append([]byte(nil), data[:20]...)
+		for i := 8; i < 20; i += 4 {
+			offset := int(data[i])<<24 | int(data[i+1])<<16 | int(data[i+2])<<8 | int(data[i+3]) - extSize
+			legacy[i], legacy[i+1], legacy[i+2], legacy[i+3] = byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset)
+		}
+		legacy = append(legacy, data[20+extSize:]...)
+		r, err = qml.ParseResources(legacy)
+		c.Assert(err, IsNil)
+		c.Assert(r.Hash(), Equals, hash)
+	}
+}
+
+func (s *S) TestLoadedResourcesHash(c *C) {
+	var rp, group qml.ResourcesPacker
+	rp.AddString("hash/Foo.qml", "<Foo>")
+	group.AddString("hash/Tutorial.qml", "<Tutorial>")
+	r := rp.Pack()
+	g := group.Pack()
+
+	// Resources loaded by other tests might still be around.
+	before := qml.LoadedResourcesHash()
+	want := before
+	if want == "" {
+		want = r.Hash()
+	}
+	qml.LoadResources(r)
+	c.Assert(qml.LoadedResourcesHash(), Equals, want)
+
+	// Resources loaded on demand afterwards don't change it.
+	qml.LoadResources(g)
+	c.Assert(qml.LoadedResourcesHash(), Equals, want)
+	qml.UnloadResources(g)
+	c.Assert(qml.LoadedResourcesHash(), Equals, want)
+
+	qml.UnloadResources(r)
+	c.Assert(qml.LoadedResourcesHash(), Equals, before)
+}
+
+func (s *S) TestResourcesDeduplicated(c *C) {
+	data := strings.Repeat("x", 1024)
+
+	var one, three qml.ResourcesPacker
+	one.AddString("a/icon.png", data)
+	three.AddString("a/icon.png", data)
+	three.AddString("b/icon.png", data)
+	three.Add("c/copy.png", []byte(data))
+
+	r := three.Pack()
+	c.Assert(len(r.Bytes())-len(one.Pack().Bytes()) < len(data), Equals, true)
+	c.Assert(r.List(), DeepEquals, []qml.ResourceInfo{
+		{Path: "a/icon.png", Size: 1024},
+		{Path: "b/icon.png", Size: 1024},
+		{Path: "c/copy.png", Size: 1024},
+	})
+}
+
 func testResourcesLoaded(c *C, loaded bool) {
 	engine := qml.NewEngine()
 	defer engine.Destroy()
@@ -679,57 +1853,340 @@ var tests = []struct {
 		},
 	},
 	{
-		Summary: "Identical values remain identical when possible",
-		Init: func(c *TestData) {
-			c.context.SetVar("a", c.value)
-			c.context.SetVar("b", c.value)
-		},
-		QML:    `Item { Component.onCompleted: console.log('Identical:', a === b); }`,
-		QMLLog: "Identical: true",
-	},
-	{
-		Summary: "Object finding via ObjectByName",
-		QML:     `Item { Item { objectName: "subitem"; property string s: "<found>" } }`,
+		Summary: "Read a nested map from a QML property as native Go values",
+		QML:     `Item { property var m: {"a": 1, "b": {"c": [1, "x", {"d": true}]}, "e": []} }`,
 		Done: func(c *TestData) {
-			obj := c.root.ObjectByName("subitem")
-			c.Check(obj.String("s"), Equals, "<found>")
-			c.Check(func() { c.root.ObjectByName("foo") }, Panics, `cannot find descendant with objectName == "foo"`)
+			c.Assert(c.root.Map("m").Native(), DeepEquals, map[string]interface{}{
+				"a": 1,
+				"b": map[string]interface{}{
+					"c": []interface{}{1, "x", map[string]interface{}{"d": true}},
+				},
+				"e": []interface{}{},
+			})
 		},
 	},
 	{
-		Summary: "Object finding via ObjectByName on GoType",
-		QML:     `Item { GoType { objectName: "subitem"; property string s: "<found>" } }`,
+		Summary: "Read a QML object as native Go values",
+		QML: `
+			QtObject {
+				id: root
+				property int n: 1
+				property var m: {"a": [1, {"b": "x"}]}
+				property color c: "#ff0000"
+				property QtObject child: QtObject {
+					property string s: "y"
+					property QtObject back: root
+				}
+			}
+		`,
 		Done: func(c *TestData) {
-			obj := c.root.ObjectByName("subitem")
-			c.Check(obj.String("s"), Equals, "<found>")
-			c.Check(func() { c.root.ObjectByName("foo") }, Panics, `cannot find descendant with objectName == "foo"`)
+			native := c.root.Native()
+			c.Assert(native["n"], Equals, 1)
+			c.Assert(native["c"], Equals, color.RGBA{255, 0, 0, 255})
+			c.Assert(native["m"], DeepEquals, map[string]interface{}{
+				"a": []interface{}{1, map[string]interface{}{"b": "x"}},
+			})
+			child, ok := native["child"].(map[string]interface{})
+			c.Assert(ok, Equals, true)
+			c.Assert(child["s"], Equals, "y")
+
+			// The cycle back to the root object is not walked into.
+			back, ok := child["back"].(qml.Object)
+			c.Assert(ok, Equals, true)
+			c.Assert(back.Int("n"), Equals, 1)
 		},
 	},
 	{
-		Summary: "Register Go type",
-		QML:     `GoType { objectName: "test"; Component.onCompleted: console.log("String is", stringValue) }`,
-		QMLLog:  "String is <initial>",
+		Summary: "Index and look up lists and maps from QML properties",
+		QML:     `Item { property var l: [10, "x", [true]]; property var m: {"a": 1}; property int i: 1 }`,
 		Done: func(c *TestData) {
-			c.Assert(c.createdValue, HasLen, 1)
-			c.Assert(c.createdValue[0].object.String("objectName"), Equals, "test")
+			l, ok := c.root.LookupList("l")
+			c.Assert(ok, Equals, true)
+			c.Assert(l.Len(), Equals, 3)
+			c.Assert(l.Index(0), Equals, 10)
+			c.Assert(l.Index(1), Equals, "x")
+			c.Assert(l.Index(2).(*qml.List).Native(), DeepEquals, []interface{}{true})
+			c.Assert(l.Native(), DeepEquals, []interface{}{10, "x", []interface{}{true}})
+
+			m, ok := c.root.LookupMap("m")
+			c.Assert(ok, Equals, true)
+			c.Assert(m.Native(), DeepEquals, map[string]interface{}{"a": 1})
+
+			_, ok = c.root.LookupList("m")
+			c.Assert(ok, Equals, false)
+			_, ok = c.root.LookupList("i")
+			c.Assert(ok, Equals, false)
+			_, ok = c.root.LookupMap("l")
+			c.Assert(ok, Equals, false)
+			_, ok = c.root.LookupMap("missing")
+			c.Assert(ok, Equals, false)
 		},
 	},
 	{
-		Summary: "Register Go type with an explicit name",
-		QML:     `NamedGoType { objectName: "test"; Component.onCompleted: console.log("String is", stringValue) }`,
-		QMLLog:  "String is <initial>",
+		Summary: "Call a QML method and convert its result",
+		QML: `
+			Item {
+				function record() { return {name: "<name>", count: 2, tags: ["x", "y"], ignored: 1} }
+				function records() { return [{name: "a"}, {name: "b"}] }
+				function table() { return {a: [1, 2], b: []} }
+				function fail() { throw new Error("<boom>") }
+			}
+		`,
 		Done: func(c *TestData) {
-			c.Assert(c.createdValue, HasLen, 1)
-			c.Assert(c.createdValue[0].object.String("objectName"), Equals, "test")
+			type Record struct {
+				Name    string
+				Count   int
+				Labels  []string `qml:"tags"`
+				Ignored int      `qml:"-"`
+			}
+			var r Record
+			c.Assert(c.root.CallAs("record", &r), IsNil)
+			c.Assert(r, DeepEquals, Record{Name: "<name>", Count: 2, Labels: []string{"x", "y"}})
+
+			var rs []Record
+			c.Assert(c.root.CallAs("records", &rs), IsNil)
+			c.Assert(rs, DeepEquals, []Record{{Name: "a"}, {Name: "b"}})
+
+			var t map[string][]int
+			c.Assert(c.root.CallAs("table", &t), IsNil)
+			c.Assert(t, DeepEquals, map[string][]int{"a": {1, 2}, "b": {}})
+
+			var any interface{}
+			c.Assert(c.root.CallAs("table", &any), IsNil)
+			c.Assert(any, DeepEquals, map[string]interface{}{"a": []interface{}{1, 2}, "b": []interface{}{}})
+
+			c.Assert(c.root.CallAs("table", nil), IsNil)
+
+			var s string
+			c.Assert(c.root.CallAs("records", &s), ErrorMatches, "cannot store result of method records: cannot use \\*qml.List as a string")
+			c.Assert(c.root.CallAs("fail", &s), ErrorMatches, ".*Error: <boom>")
+			c.Assert(c.root.CallAs("missing", &s), ErrorMatches, `object does not expose a method "missing"`)
+			c.Assert(c.root.CallAs("record", r), ErrorMatches, "CallAs got a result parameter that is not a non-nil pointer: .*")
 		},
 	},
 	{
-		Summary: "Write Go type property",
-		QML:     `GoType { stringValue: "<content>"; intValue: 300 }`,
+		Summary: "Report exceptions thrown by QML methods",
+		QML: `
+			Item {
+				function check(n) {
+					if (n < 0) {
+						throw new Error("<negative>")
+					}
+					return n
+				}
+				property var source: {"width": 1}
+				property int width2: source.width * 2
+				function breakBinding() { source = null; return "<done>" }
+				function throwString() { throw "<thrown>" }
+			}
+		`,
 		Done: func(c *TestData) {
-			c.Assert(c.createdValue, HasLen, 1)
-			c.Assert(c.createdValue[0].StringValue, Equals, "<content>")
-			c.Assert(c.createdValue[0].IntValue, Equals, 300)
+			err := c.root.CallAs("check", nil, -1)
+			c.Assert(err, ErrorMatches, ".*:6 Error: <negative>")
+			qerr, ok := err.(*qml.Error)
+			c.Assert(ok, Equals, true)
+			c.Assert(qerr.URL, Matches, ".*file.qml")
+			c.Assert(qerr.Line, Equals, 6)
+			c.Assert(qerr.Message, Equals, "Error: <negative>")
+			c.Assert(qerr.Stack, Matches, "(?s).*check.*")
+
+			err = c.root.CallAs("throwString", nil)
+			qerr, ok = err.(*qml.Error)
+			c.Assert(ok, Equals, true)
+			c.Assert(qerr.Message, Equals, "<thrown>")
+			c.Assert(qerr.Line, Equals, -1)
+
+			c.Assert(c.root.Call("check", 1), Equals, 1)
+
+			// Warnings from bindings broken as a side effect are not exceptions.
+			var s string
+			c.Assert(c.root.CallAs("breakBinding", &s), IsNil)
+			c.Assert(s, Equals, "<done>")
+			c.root.Set("source", map[string]interface{}{"width": 1})
+			c.Assert(c.root.Call("breakBinding"), Equals, "<done>")
+
+			// Call keeps returning nil on exceptions.
+			c.Assert(c.root.Call("check", -1), IsNil)
+		},
+	},
+	{
+		Summary: "Evaluate expressions in the scope of an object",
+		QML: `
+			Item {
+				id: item
+				width: 20
+				property string label: "<label>"
+				function double(n) { return n * 2 }
+				Item { objectName: "child"; property int n: item.width + 1 }
+			}
+		`,
+		Done: func(c *TestData) {
+			value, err := c.root.Eval("double(width) + 2")
+			c.Assert(err, IsNil)
+			c.Assert(value, Equals, 42)
+
+			value, err = c.root.Eval("label.toUpperCase()")
+			c.Assert(err, IsNil)
+			c.Assert(value, Equals, "<LABEL>")
+
+			value, err = c.root.Eval("[width, label]")
+			c.Assert(err, IsNil)
+			c.Assert(value.(*qml.List).Len(), Equals, 2)
+
+			child := c.root.ObjectByName("child")
+			value, err = child.Eval("n + item.width")
+			c.Assert(err, IsNil)
+			c.Assert(value, Equals, 41)
+
+			_, err = c.root.Eval("width = 30")
+			c.Assert(err, IsNil)
+			c.Assert(c.root.Int("width"), Equals, 30)
+
+			_, err = c.root.Eval("missing.property")
+			c.Assert(err, ErrorMatches, ".*missing is not defined")
+
+			_, err = c.root.Eval("(function() { throw new Error('<boom>') })()")
+			c.Assert(err, ErrorMatches, ".*<boom>")
+		},
+	},
+	{
+		Summary: "Snapshot and restore the state of an object tree",
+		QML: `
+			Item {
+				id: item
+				width: 10
+				property string label: "<before>"
+				property var tags: ["a", "b"]
+				property Item self: item
+				readonly property int fixed: 7
+				Rectangle {
+					objectName: "rect"
+					color: "red"
+					height: item.width * 2
+				}
+			}
+		`,
+		Done: func(c *TestData) {
+			snapshot := c.root.Snapshot()
+			c.Assert(snapshot["width"], Equals, 10.0)
+			c.Assert(snapshot["label"], Equals, "<before>")
+			c.Assert(snapshot["tags"], DeepEquals, []interface{}{"a", "b"})
+			_, ok := snapshot["self"]
+			c.Assert(ok, Equals, false)
+			_, ok = snapshot["fixed"]
+			c.Assert(ok, Equals, false)
+			children := snapshot["@children"].([]map[string]interface{})
+			c.Assert(children, HasLen, 1)
+			c.Assert(children[0]["objectName"], Equals, "rect")
+			c.Assert(children[0]["color"], Equals, qml.Color{R: 255, A: 255})
+			c.Assert(children[0]["height"], Equals, 20.0)
+
+			rect := c.root.ObjectByName("rect")
+			c.root.Set("label", "<after>")
+			c.root.Set("tags", []string{"c"})
+			c.root.Set("width", 15)
+			rect.Set("color", "blue")
+			c.Assert(rect.Int("height"), Equals, 30)
+
+			c.Assert(c.root.Restore(snapshot), IsNil)
+			c.Assert(c.root.String("label"), Equals, "<before>")
+			c.Assert(c.root.List("tags").Native(), DeepEquals, []interface{}{"a", "b"})
+			c.Assert(c.root.Int("width"), Equals, 10)
+			c.Assert(rect.Color("color"), Equals, qml.Color{R: 255, A: 255})
+			c.Assert(rect.Int("height"), Equals, 20)
+
+			// The height binding was left untouched, as its value matched.
+			c.root.Set("width", 12)
+			c.Assert(rect.Int("height"), Equals, 24)
+
+			err := c.root.Restore(map[string]interface{}{"fixed": 1})
+			c.Assert(err, ErrorMatches, "cannot restore property fixed: .*")
+			err = c.root.Restore(map[string]interface{}{"@children": []interface{}{}})
+			c.Assert(err, ErrorMatches, "cannot restore 0 children into object with 1 children")
+		},
+	},
+	{
+		Summary: "Bind a property to an expression",
+		QML: `
+			Item {
+				width: 100
+				Item { objectName: "child" }
+			}
+		`,
+		Done: func(c *TestData) {
+			child := c.root.ObjectByName("child")
+			c.Assert(child.SetBinding("width", "parent.width / 2 // half"), IsNil)
+			c.Assert(child.Int("width"), Equals, 50)
+			c.root.Set("width", 200)
+			c.Assert(child.Int("width"), Equals, 100)
+
+			c.Assert(child.SetBinding("anchors.leftMargin", "width + 1"), IsNil)
+			margin, err := child.PropertyAt("anchors.leftMargin")
+			c.Assert(err, IsNil)
+			c.Assert(margin, Equals, 101.0)
+
+			// Setting a plain value drops the binding.
+			child.Set("width", 10)
+			c.root.Set("width", 300)
+			c.Assert(child.Int("width"), Equals, 10)
+
+			c.Assert(child.SetBinding("missing", "1"), ErrorMatches, `object does not have a "missing" property`)
+			c.Assert(child.SetBinding("width; x", "1"), ErrorMatches, `invalid property name: "width; x"`)
+			c.Assert(child.SetBinding("width", "1 +"), ErrorMatches, "cannot bind property width: .*")
+		},
+	},
+	{
+		Summary: "Identical values remain identical when possible",
+		Init: func(c *TestData) {
+			c.context.SetVar("a", c.value)
+			c.context.SetVar("b", c.value)
+		},
+		QML:    `Item { Component.onCompleted: console.log('Identical:', a === b); }`,
+		QMLLog: "Identical: true",
+	},
+	{
+		Summary: "Object finding via ObjectByName",
+		QML:     `Item { Item { objectName: "subitem"; property string s: "<found>" } }`,
+		Done: func(c *TestData) {
+			obj := c.root.ObjectByName("subitem")
+			c.Check(obj.String("s"), Equals, "<found>")
+			c.Check(func() { c.root.ObjectByName("foo") }, Panics, `cannot find descendant with objectName == "foo"`)
+		},
+	},
+	{
+		Summary: "Object finding via ObjectByName on GoType",
+		QML:     `Item { GoType { objectName: "subitem"; property string s: "<found>" } }`,
+		Done: func(c *TestData) {
+			obj := c.root.ObjectByName("subitem")
+			c.Check(obj.String("s"), Equals, "<found>")
+			c.Check(func() { c.root.ObjectByName("foo") }, Panics, `cannot find descendant with objectName == "foo"`)
+		},
+	},
+	{
+		Summary: "Register Go type",
+		QML:     `GoType { objectName: "test"; Component.onCompleted: console.log("String is", stringValue) }`,
+		QMLLog:  "String is <initial>",
+		Done: func(c *TestData) {
+			c.Assert(c.createdValue, HasLen, 1)
+			c.Assert(c.createdValue[0].object.String("objectName"), Equals, "test")
+		},
+	},
+	{
+		Summary: "Register Go type with an explicit name",
+		QML:     `NamedGoType { objectName: "test"; Component.onCompleted: console.log("String is", stringValue) }`,
+		QMLLog:  "String is <initial>",
+		Done: func(c *TestData) {
+			c.Assert(c.createdValue, HasLen, 1)
+			c.Assert(c.createdValue[0].object.String("objectName"), Equals, "test")
+		},
+	},
+	{
+		Summary: "Write Go type property",
+		QML:     `GoType { stringValue: "<content>"; intValue: 300 }`,
+		Done: func(c *TestData) {
+			c.Assert(c.createdValue, HasLen, 1)
+			c.Assert(c.createdValue[0].StringValue, Equals, "<content>")
+			c.Assert(c.createdValue[0].IntValue, Equals, 300)
 		},
 	},
 	{
@@ -1069,6 +2526,285 @@ var tests = []struct {
 			c.Check(root.Int("height"), Equals, 200)
 		},
 	},
+	{
+		Summary: "Grab the contents of a window",
+		QML:     `Rectangle { width: 100; height: 100; color: "#ff0000" }`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			_, err := win.Grab()
+			c.Assert(err, ErrorMatches, "cannot grab window contents: window is not exposed")
+
+			win.Show()
+			var img image.Image
+			for i := 0; i < 50; i++ {
+				img, err = win.Grab()
+				if err == nil {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			c.Assert(err, IsNil)
+			c.Assert(img, FitsTypeOf, &image.RGBA{})
+			bounds := img.Bounds()
+			center := img.At(bounds.Dx()/2, bounds.Dy()/2)
+			c.Assert(center, Equals, color.RGBA{255, 0, 0, 255})
+		},
+	},
+	{
+		Summary: "Set the window icon",
+		QML:     `Item {}`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			small := image.NewRGBA(image.Rect(0, 0, 16, 16))
+			large := image.NewRGBA(image.Rect(0, 0, 64, 64))
+			win.SetIcon(small, large)
+			win.SetIcon()
+
+			f, err := ioutil.TempFile("", "qml-icon")
+			c.Assert(err, IsNil)
+			defer os.Remove(f.Name())
+			c.Assert(png.Encode(f, small), IsNil)
+			c.Assert(f.Close(), IsNil)
+
+			c.Assert(win.SetIconFile(f.Name()), IsNil)
+			c.Assert(win.SetIconFile(f.Name()+".missing"), ErrorMatches, "cannot load window icon from .*missing")
+		},
+	},
+	{
+		Summary: "Toggle fullscreen, frameless, and always-on-top window modes",
+		QML: `
+			import QtQuick 2.0
+			import QtQuick.Window 2.1
+			Window {
+				width: 100; height: 100
+				function frameless() { return (flags & Qt.FramelessWindowHint) != 0 }
+				function onTop() { return (flags & Qt.WindowStaysOnTopHint) != 0 }
+				function fullscreen() { return visibility == Window.FullScreen }
+			}
+		`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			win.SetFrameless(true)
+			c.Check(win.Call("frameless"), Equals, true)
+			win.SetAlwaysOnTop(true)
+			c.Check(win.Call("onTop"), Equals, true)
+
+			win.Show()
+			done := make(chan bool)
+			go func() {
+				win.Wait()
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			win.SetFrameless(false)
+			c.Check(win.Call("frameless"), Equals, false)
+			c.Check(win.Call("onTop"), Equals, true)
+			c.Check(win.Bool("visible"), Equals, true)
+			win.SetAlwaysOnTop(false)
+			c.Check(win.Call("onTop"), Equals, false)
+			select {
+			case <-done:
+				c.Fatalf("Wait returned while the window flags were changed")
+			case <-time.After(100 * time.Millisecond):
+			}
+			win.Hide()
+			select {
+			case <-done:
+			case <-time.After(3 * time.Second):
+				c.Fatalf("Wait did not return after the window was hidden")
+			}
+			win.Show()
+
+			win.SetFullscreen(true)
+			c.Check(win.Call("fullscreen"), Equals, true)
+			win.SetFullscreen(false)
+			c.Check(win.Call("fullscreen"), Equals, false)
+		},
+	},
+	{
+		Summary: "Control the mouse cursor",
+		QML: `
+			Item {
+				property var shapes: [Qt.ArrowCursor, Qt.WaitCursor, Qt.BlankCursor, Qt.BusyCursor, Qt.DragLinkCursor]
+			}
+		`,
+		Done: func(c *TestData) {
+			shapes := []qml.CursorShape{qml.ArrowCursor, qml.WaitCursor, qml.BlankCursor, qml.BusyCursor, qml.DragLinkCursor}
+			for i, shape := range shapes {
+				c.Check(c.root.List("shapes").Index(i), Equals, int(shape))
+			}
+
+			qml.SetOverrideCursor(qml.WaitCursor)
+			qml.SetOverrideCursor(qml.BusyCursor)
+			qml.RestoreOverrideCursor()
+			qml.RestoreOverrideCursor()
+			qml.RestoreOverrideCursor()
+			c.Check(func() { qml.SetOverrideCursor(qml.CursorShape(99)) }, Panics, "invalid cursor shape: 99")
+
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+			win.SetCursorVisible(false)
+			win.Show()
+			win.SetCursorVisible(true)
+		},
+	},
+	{
+		Summary: "Query and set the window position and geometry",
+		QML:     `Item {}`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			win.SetGeometry(10, 20, 300, 200)
+			x, y, width, height := win.Geometry()
+			c.Check([]int{x, y, width, height}, DeepEquals, []int{10, 20, 300, 200})
+
+			win.SetPosition(30, 40)
+			x, y = win.Position()
+			c.Check([]int{x, y}, DeepEquals, []int{30, 40})
+			_, _, width, height = win.Geometry()
+			c.Check([]int{width, height}, DeepEquals, []int{300, 200})
+
+			screen := win.Screen()
+			c.Assert(screen, NotNil)
+			_, _, width, height = screen.Geometry()
+			c.Check(width > 0 && height > 0, Equals, true)
+			screen.Name()
+		},
+	},
+	{
+		Summary: "Query the window device pixel ratio",
+		QML:     `Item {}`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			c.Check(win.DevicePixelRatio() >= 1, Equals, true)
+
+			var ratios []float64
+			conn := win.OnDevicePixelRatioChange(func(ratio float64) { ratios = append(ratios, ratio) })
+			conn.Disconnect()
+			c.Check(ratios, IsNil)
+		},
+	},
+	{
+		Summary: "Veto closing a window",
+		QML:     `Item {}`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+
+			var calls int
+			allow := false
+			win.OnClose(func() bool {
+				calls++
+				return allow
+			})
+			win.Show()
+			c.Check(cpptest.SendCloseEvent(&win.Common), Equals, false)
+			c.Check(win.Bool("visible"), Equals, true)
+			c.Check(calls, Equals, 1)
+
+			allow = true
+			c.Check(cpptest.SendCloseEvent(&win.Common), Equals, true)
+			c.Check(calls, Equals, 2)
+
+			win.OnClose(nil)
+			c.Check(cpptest.SendCloseEvent(&win.Common), Equals, true)
+			c.Check(calls, Equals, 2)
+		},
+	},
+	{
+		Summary: "Wait for a window with a context",
+		QML:     `Item {}`,
+		Done: func(c *TestData) {
+			win := c.component.CreateWindow(nil)
+			defer win.Destroy()
+			win.Show()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			c.Assert(win.WaitContext(ctx), Equals, context.DeadlineExceeded)
+
+			// The window is left open, and the event loop running.
+			c.Assert(win.Bool("visible"), Equals, true)
+			ran := make(chan bool, 1)
+			qml.Go(func() { ran <- true })
+			select {
+			case <-ran:
+			case <-time.After(5 * time.Second):
+				c.Fatalf("event loop stopped after WaitContext returned")
+			}
+
+			done := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() { done <- win.WaitContext(context.Background()) }()
+			}
+			time.Sleep(50 * time.Millisecond)
+			win.Hide()
+			for i := 0; i < 2; i++ {
+				select {
+				case err := <-done:
+					c.Assert(err, IsNil)
+				case <-time.After(5 * time.Second):
+					c.Fatalf("WaitContext did not return after the window was hidden")
+				}
+			}
+		},
+	},
+	{
+		Summary: "Change a property from a goroutine via RunMain",
+		QML:     `Item { property int n: 1; property int double: n * 2 }`,
+		Done: func(c *TestData) {
+			done := make(chan int)
+			go func() {
+				var double int
+				qml.RunMain(func() {
+					c.root.Set("n", 21)
+					double = c.root.Int("double")
+				})
+				done <- double
+			}()
+			c.Assert(<-done, Equals, 42)
+			c.Assert(c.root.Int("n"), Equals, 21)
+		},
+	},
+	{
+		Summary: "Schedule functions in the GUI thread via Go",
+		QML:     `Item { property int n }`,
+		Done: func(c *TestData) {
+			var order []int
+			done := make(chan bool)
+			for i := 1; i <= 3; i++ {
+				i := i
+				qml.Go(func() {
+					c.root.Set("n", i)
+					order = append(order, i)
+				})
+			}
+			qml.Go(func() { done <- true })
+			<-done
+			c.Assert(order, DeepEquals, []int{1, 2, 3})
+			c.Assert(c.root.Int("n"), Equals, 3)
+		},
+	},
+	{
+		Summary: "Flush pending events",
+		QML:     `Item { property bool fired; Timer { id: timer; interval: 0; onTriggered: fired = true }; function start() { timer.start() } }`,
+		Done: func(c *TestData) {
+			qml.RunMain(func() {
+				c.root.Call("start")
+				qml.Flush()
+				c.Assert(c.root.Bool("fired"), Equals, true)
+			})
+		},
+	},
 	{
 		Summary: "Window is object",
 		QML:     `Item {}`,
@@ -1104,6 +2840,25 @@ var tests = []struct {
 		},
 		DoneLog: "item destroyed",
 	},
+	{
+		Summary: "Watch a property change via its notification signal",
+		QML:     `Item { property int n: 1; function bump() { n++ } }`,
+		Done: func(c *TestData) {
+			var seen []int
+			conn, err := c.root.OnChange("n", func() { seen = append(seen, c.root.Int("n")) })
+			c.Assert(err, IsNil)
+			c.root.Call("bump")
+			c.root.Set("n", 42)
+			c.Assert(seen, DeepEquals, []int{2, 42})
+
+			conn.Disconnect()
+			c.root.Set("n", 43)
+			c.Assert(seen, DeepEquals, []int{2, 42})
+
+			_, err = c.root.OnChange("missing", func() {})
+			c.Assert(err, ErrorMatches, `object does not have a "missing" property`)
+		},
+	},
 	{
 		Summary: "Errors connecting to QML signals",
 		QML:     `Item { signal doIt() }`,
@@ -1130,53 +2885,223 @@ var tests = []struct {
 		},
 	},
 	{
-		Summary: "Connect to a QML signal with a parameters",
+		Summary: "Disconnect a function from a QML signal",
 		QML: `
 			Item {
 				id: item
-				signal doIt(string s, int n)
-				function emitDoIt() { item.doIt("<arg>", 123) }
+				signal doIt()
+				function emitDoIt() { item.doIt() }
 			}
 		`,
 		Done: func(c *TestData) {
-			var stack []interface{}
-			c.root.On("doIt", func() { stack = append(stack, "A") })
-			c.root.On("doIt", func(s string) { stack = append(stack, "B", s) })
-			c.root.On("doIt", func(s string, i int) { stack = append(stack, "C", s, i) })
-			c.Check(stack, IsNil)
+			calls := 0
+			conn := c.root.On("doIt", func() { calls++ })
 			c.root.Call("emitDoIt")
-			c.Check(stack, DeepEquals, []interface{}{"A", "B", "<arg>", "C", "<arg>", 123})
+			conn.Disconnect()
+			c.root.Call("emitDoIt")
+			c.Check(calls, Equals, 1)
+
+			// Disconnecting again is a no-op.
+			conn.Disconnect()
+
+			_, err := c.root.OnConnection("missing", func() {})
+			c.Check(err, ErrorMatches, `object does not expose a "missing" signal`)
 		},
 	},
 	{
-		Summary: "Connect to a QML signal with an object parameter",
-		QML:     `import QtWebKit 3.0; WebView{}`,
+		Summary: "Connect a function to a single emission of a QML signal",
+		QML: `
+			Item {
+				id: item
+				signal doIt()
+				function emitDoIt() { item.doIt() }
+			}
+		`,
 		Done: func(c *TestData) {
-			url := "http://localhost:54321/"
-			done := make(chan bool)
-			c.root.On("navigationRequested", func(request qml.Object) {
-				c.Check(request.String("url"), Equals, url)
-				done <- true
+			calls := 0
+			c.root.Once("doIt", func() {
+				calls++
+				// Reentrant emissions must not call it again.
+				c.root.Call("emitDoIt")
 			})
-			c.root.Set("url", url)
-			<-done
+			c.root.Call("emitDoIt")
+			c.root.Call("emitDoIt")
+			c.Check(calls, Equals, 1)
+
+			cancelled := false
+			conn := c.root.Once("doIt", func() { cancelled = true })
+			conn.Disconnect()
+			c.root.Call("emitDoIt")
+			c.Check(cancelled, Equals, false)
 		},
 	},
 	{
-		Summary: "Load image from Go provider",
-		Init: func(c *TestData) {
-			c.engine.AddImageProvider("myprov", func(id string, width, height int) image.Image {
-				return image.NewRGBA(image.Rect(0, 0, 200, 100))
-			})
-		},
+		Summary: "Receive QML signal emissions over a channel",
 		QML: `
-			Image {
-				source: "image://myprov/myid.png"
-				Component.onCompleted: console.log("Size:", width, height)
+			Item {
+				id: item
+				signal doIt(string s, int n)
+				function emitDoIt(n) { item.doIt("<arg>", n) }
+			}
+		`,
+		Done: func(c *TestData) {
+			ch, cancel := c.root.SignalChan("doIt", 1)
+			c.root.Call("emitDoIt", 1)
+			c.root.Call("emitDoIt", 2)
+			c.Check(<-ch, DeepEquals, []interface{}{"<arg>", 1})
+			c.Check(qml.Stats().SignalsDropped, Equals, 1)
+
+			cancel()
+			c.root.Call("emitDoIt", 3)
+			_, ok := <-ch
+			c.Check(ok, Equals, false)
+
+			// Cancelling again is a no-op.
+			cancel()
+		},
+	},
+	{
+		Summary: "Emit a QML signal from Go",
+		QML: `
+			Item {
+				signal updated(string name, int count)
+				property string seen
+				onUpdated: seen = name + "=" + count
+			}
+		`,
+		Done: func(c *TestData) {
+			var got []interface{}
+			c.root.On("updated", func(name string, count int) { got = append(got, name, count) })
+
+			err := c.root.Emit("updated", "items", 42)
+			c.Assert(err, IsNil)
+			c.Check(c.root.String("seen"), Equals, "items=42")
+			c.Check(got, DeepEquals, []interface{}{"items", 42})
+
+			err = c.root.Emit("updated", "items")
+			c.Check(err, ErrorMatches, `signal "updated" does not take 1 parameters`)
+			err = c.root.Emit("updated", "items", c.root)
+			c.Check(err, ErrorMatches, `cannot use .* as parameter 2 of signal "updated" \(int\)`)
+			err = c.root.Emit("missing")
+			c.Check(err, ErrorMatches, `object does not expose a "missing" signal`)
+		},
+	},
+	{
+		Summary: "Connect to a QML signal with a parameters",
+		QML: `
+			Item {
+				id: item
+				signal doIt(string s, int n)
+				function emitDoIt() { item.doIt("<arg>", 123) }
+			}
+		`,
+		Done: func(c *TestData) {
+			var stack []interface{}
+			c.root.On("doIt", func() { stack = append(stack, "A") })
+			c.root.On("doIt", func(s string) { stack = append(stack, "B", s) })
+			c.root.On("doIt", func(s string, i int) { stack = append(stack, "C", s, i) })
+			c.Check(stack, IsNil)
+			c.root.Call("emitDoIt")
+			c.Check(stack, DeepEquals, []interface{}{"A", "B", "<arg>", "C", "<arg>", 123})
+		},
+	},
+	{
+		Summary: "Connect to a QML signal with typed parameters",
+		QML: `
+			Item {
+				id: item
+				signal changed(int n, string s, Item obj, var list, var record, real x)
+				function emitChanged() { item.changed(42, "<arg>", item, [1, 2], {name: "<name>"}, 1.5) }
+				function emitEmpty() { item.changed(0, "", null, undefined, undefined, 0) }
+			}
+		`,
+		Done: func(c *TestData) {
+			type Record struct{ Name string }
+			var got []interface{}
+			c.root.On("changed", func(n int64, s string, obj qml.Object, list []int, record Record, x float32) {
+				got = append(got, n, s, obj, list, record, x)
+			})
+			c.root.Call("emitChanged")
+			c.Assert(got, HasLen, 6)
+			c.Check(got[0], Equals, int64(42))
+			c.Check(got[1], Equals, "<arg>")
+			c.Check(got[2].(qml.Object).Addr(), Equals, c.root.Addr())
+			c.Check(got[3], DeepEquals, []int{1, 2})
+			c.Check(got[4], Equals, Record{Name: "<name>"})
+			c.Check(got[5], Equals, float32(1.5))
+
+			got = nil
+			c.root.Call("emitEmpty")
+			c.Check(got, DeepEquals, []interface{}{int64(0), "", qml.Object(nil), []int(nil), Record{}, float32(0)})
+
+			_, err := c.root.OnConnection("changed", func(n string) {})
+			c.Check(err, ErrorMatches, `signal "changed" parameter 0 is a int; cannot convert it to string`)
+			_, err = c.root.OnConnection("changed", func(n int, s bool) {})
+			c.Check(err, ErrorMatches, `signal "changed" parameter 1 is a QString; cannot convert it to bool`)
+			_, err = c.root.OnConnection("changed", func(n int, s string, obj string) {})
+			c.Check(err, ErrorMatches, `signal "changed" parameter 2 is a QQuickItem\*; cannot convert it to string`)
+			_, err = c.root.OnConnection("changed", func(n, s, obj, list, record, x, extra interface{}) {})
+			c.Check(err, ErrorMatches, `signal "changed" has too few parameters for provided function`)
+
+			// Values of undeclared types that cannot be converted are reported
+			// when the signal is emitted, and the function is not called.
+			called := false
+			conn, err := c.root.OnConnection("changed", func(n int, s string, obj qml.Object, list string) { called = true })
+			c.Assert(err, IsNil)
+			c.root.Call("emitChanged")
+			conn.Disconnect()
+			c.Check(called, Equals, false)
+			c.Check(c.GetTestLog(), Matches, "(?s).*cannot convert parameter 3 of signal handler from \\*qml.List to string.*")
+		},
+	},
+	{
+		Summary: "Connect to a QML signal with an object parameter",
+		QML:     `import QtWebKit 3.0; WebView{}`,
+		Done: func(c *TestData) {
+			url := "http://localhost:54321/"
+			done := make(chan bool)
+			c.root.On("navigationRequested", func(request qml.Object) {
+				c.Check(request.String("url"), Equals, url)
+				done <- true
+			})
+			c.root.Set("url", url)
+			<-done
+		},
+	},
+	{
+		Summary: "Load image from Go provider",
+		Init: func(c *TestData) {
+			c.engine.AddImageProvider("myprov", func(id string, width, height int) image.Image {
+				return image.NewRGBA(image.Rect(0, 0, 200, 100))
+			})
+		},
+		QML: `
+			Image {
+				source: "image://myprov/myid.png"
+				Component.onCompleted: console.log("Size:", width, height)
 			}
 		`,
 		QMLLog: "Size: 200 100",
 	},
+	{
+		Summary: "Image provider failing to provide an image",
+		Init: func(c *TestData) {
+			c.engine.AddImageProviderWithError("failprov", func(id string, width, height int) (image.Image, error) {
+				if id == "good" {
+					return image.NewRGBA(image.Rect(0, 0, 20, 10)), nil
+				}
+				return nil, fmt.Errorf("<no %s>", id)
+			})
+		},
+		QML: `
+			Item {
+				Image { id: good; source: "image://failprov/good" }
+				Image { id: bad; source: "image://failprov/bad" }
+				Component.onCompleted: console.log("Status:", good.status == Image.Ready, bad.status == Image.Error)
+			}
+		`,
+		QMLLog: "cannot provide image bad: <no bad>.*Status: true true",
+	},
 	{
 		Summary: "TypeName",
 		QML:     `Item{}`,
@@ -1211,6 +3136,32 @@ var tests = []struct {
 			c.Assert(image.At(100, 100), Equals, color.RGBA{255, 0, 0, 255})
 		},
 	},
+	{
+		Summary: "Measure and draw text with a painter, with render hints",
+		QML: `
+			Rectangle {
+				width: 200; height: 200
+				GoRect {
+					width: 100; height: 100
+					text: "Line\nLonger line"
+				}
+			}
+		`,
+		Done: func(c *TestData) {
+			window := c.component.CreateWindow(nil)
+			defer window.Destroy()
+			window.Show()
+			time.Sleep(100 * time.Millisecond)
+
+			c.Assert(c.createdRect, HasLen, 1)
+			rect := c.createdRect[0]
+			c.Assert(rect.PaintCount > 0, Equals, true)
+			c.Assert(rect.lineExtents[0] > 0 && rect.lineExtents[1] > 0, Equals, true)
+			c.Assert(rect.textExtents[0] > rect.lineExtents[0], Equals, true)
+			c.Assert(rect.textExtents[1] >= 2*rect.lineExtents[1]-1, Equals, true)
+			c.Assert(rect.hints, DeepEquals, []bool{true, false})
+		},
+	},
 	{
 		Summary: "Set a property with the wrong type",
 		QML: `
@@ -1294,6 +3245,1064 @@ var tests = []struct {
 	},
 }
 
+func (s *S) TestRegisterTypesAgain(c *C) {
+	spec := func(text string, singleton bool) qml.TypeSpec {
+		return qml.TypeSpec{
+			Init: func(v *GoType, obj qml.Object) {
+				v.StringValue = text
+			},
+			Singleton: singleton,
+		}
+	}
+	qml.RegisterTypes("GoTypesAgain", 1, 0, []qml.TypeSpec{spec("<first>", false)})
+	qml.RegisterTypes("GoTypesAgain", 1, 0, []qml.TypeSpec{spec("<second>", false)})
+
+	component, err := s.engine.LoadString("file.qml", "import GoTypesAgain 1.0\nGoType {}")
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.String("stringValue"), Equals, "<second>")
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoTypesAgain", 1, 0, []qml.TypeSpec{spec("<singleton>", true)})
+	}, PanicMatches, `cannot register singleton \*qml_test.GoType as GoTypesAgain 1.0 GoType: already registered as \*qml_test.GoType`)
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoTypesAgain", 1, 0, []qml.TypeSpec{{
+			Name: "GoType",
+			Init: func(v *GoRect, obj qml.Object) {},
+		}})
+	}, PanicMatches, `cannot register \*qml_test.GoRect as GoTypesAgain 1.0 GoType: already registered as \*qml_test.GoType`)
+}
+
+type GoTagged struct {
+	Name     string `qml:"fullName,notify"`
+	Password string `qml:"-"`
+	Age      int
+}
+
+func (t *GoTagged) SetName(name string) {
+	t.Name = strings.Title(name)
+	qml.Changed(t, &t.Name)
+}
+
+func (s *S) TestRegisterTaggedFields(c *C) {
+	var tagged *GoTagged
+	qml.RegisterTypes("GoTagged", 1, 0, []qml.TypeSpec{{
+		Init: func(v *GoTagged, obj qml.Object) { tagged = v },
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoTagged 1.0
+		GoTagged {
+			fullName: "ale"
+			age: 42
+			property string seen: fullName
+			property bool hasPassword: password !== undefined
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(tagged.Name, Equals, "Ale")
+	c.Assert(tagged.Age, Equals, 42)
+	c.Assert(root.String("seen"), Equals, "Ale")
+	c.Assert(root.Bool("hasPassword"), Equals, false)
+
+	tagged.SetName("gopher")
+	c.Assert(root.String("seen"), Equals, "Gopher")
+
+	type badTag struct {
+		Name string `qml:"name,bogus"`
+	}
+	c.Assert(func() {
+		qml.RegisterTypes("GoTagged", 1, 0, []qml.TypeSpec{{Init: func(v *badTag, obj qml.Object) {}}})
+	}, PanicMatches, `unknown option "bogus" in qml tag of field qml_test.badTag.Name`)
+}
+
+type GoNotified struct {
+	First string `qml:"first,notify"`
+	Last  string `qml:"last,notify"`
+	Full  string
+}
+
+func (n *GoNotified) SetFull(full string) {
+	n.Full = full
+	parts := strings.SplitN(full, " ", 2)
+	n.First, n.Last = parts[0], parts[1]
+}
+
+func (n *GoNotified) Rename(first string) {
+	n.First = first
+}
+
+func (n *GoNotified) URLFor(path string) string {
+	return "http://example.com/" + path
+}
+
+func (s *S) TestNotifyTaggedFields(c *C) {
+	value := &GoNotified{First: "Ada", Last: "Lovelace"}
+	s.context.SetVar("value", value)
+
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			property string name: value.first + " " + value.last
+			function setFull(full) { value.full = full }
+			function rename(first) { value.rename(first) }
+			property QtObject target: value
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("name"), Equals, "Ada Lovelace")
+
+	root.Call("setFull", "Grace Hopper")
+	c.Assert(value.Full, Equals, "Grace Hopper")
+	c.Assert(root.String("name"), Equals, "Grace Hopper")
+
+	root.Call("rename", "Margaret")
+	c.Assert(root.String("name"), Equals, "Margaret Hopper")
+
+	// CallAsync finds methods by the names QML knows them as,
+	// and signals changes to notify fields once they return.
+	results := make(chan interface{}, 1)
+	done := func(result interface{}, err error) {
+		c.Check(err, IsNil)
+		results <- result
+	}
+	target := root.Object("target")
+	target.CallAsync("urlFor", done, "docs")
+	c.Assert(<-results, Equals, "http://example.com/docs")
+	target.CallAsync("rename", done, "Ada")
+	<-results
+	c.Assert(root.String("name"), Equals, "Ada Hopper")
+}
+
+func (s *S) TestTimeRoundTrip(c *C) {
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			function echo(d) { return d }
+			function year(d) { return d.getUTCFullYear() }
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	times := []time.Time{
+		time.Date(2014, 7, 1, 12, 30, 45, 123456789, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999000000, time.UTC),
+		time.Date(2014, 7, 1, 12, 30, 45, 0, time.FixedZone("X", -3*3600-1800)),
+	}
+	if loc, err := time.LoadLocation("America/New_York"); err == nil {
+		// Around the DST transitions in 2014.
+		times = append(times,
+			time.Date(2014, 3, 9, 1, 59, 59, 500000000, loc),
+			time.Date(2014, 3, 9, 3, 0, 0, 0, loc),
+			time.Date(2014, 11, 2, 1, 30, 0, 0, loc).Add(time.Hour),
+			time.Date(2014, 11, 2, 2, 0, 0, 0, loc),
+		)
+	} else {
+		c.Logf("Skipping DST checks: %v", err)
+	}
+	for _, t := range times {
+		result := root.Call("echo", t)
+		c.Assert(result, FitsTypeOf, time.Time{})
+		got := result.(time.Time)
+		c.Check(got.Location(), Equals, time.UTC)
+		c.Check(got.Equal(t.Truncate(time.Millisecond)), Equals, true, Commentf("sent %v, got %v", t, got))
+		c.Check(root.Call("year", t), Equals, t.UTC().Year())
+	}
+}
+
+func (s *S) TestRegisterConverterDateTime(c *C) {
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject { function echo(d) { return d } }
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	var plain qml.Object
+	qml.RegisterConverter("QDateTime", func(engine *qml.Engine, obj qml.Object) interface{} {
+		c.Check(obj.String("plainType"), Equals, "QDateTime")
+		c.Check(obj.Property("plainAddr"), FitsTypeOf, uintptr(0))
+		plain = obj
+		return "converted"
+	})
+	defer qml.RegisterConverter("QDateTime", nil)
+
+	t := time.Date(2014, 7, 1, 12, 30, 45, 0, time.UTC)
+	c.Assert(root.Call("echo", t), Equals, "converted")
+
+	// The plain object only lives while the converter runs.
+	c.Assert(plain, NotNil)
+	c.Assert(func() { plain.String("plainType") }, Panics, "object was destroyed")
+
+	// A nil converter restores the default one.
+	qml.RegisterConverter("QDateTime", nil)
+	c.Assert(root.Call("echo", t), Equals, t)
+}
+
+func (s *S) TestBytesRoundTrip(c *C) {
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			property var data
+			function echo(b) { return b }
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	c.Assert(root.Call("echo", data), DeepEquals, data)
+
+	root.Set("data", data)
+	c.Assert(root.Property("data"), DeepEquals, data)
+
+	c.Assert(root.Call("echo", []byte{}), DeepEquals, []byte{})
+}
+
+type GoCollections struct {
+	Items   []interface{}
+	Strings []string
+	Counts  map[string]int
+}
+
+func (g *GoCollections) Names() []string {
+	return []string{"a", "b", "c"}
+}
+
+type GoItems []int
+
+func (items GoItems) Total() int {
+	total := 0
+	for _, item := range items {
+		total += item
+	}
+	return total
+}
+
+type GoCart struct {
+	Items GoItems
+}
+
+func (s *S) TestSliceWithMethods(c *C) {
+	s.context.SetVar("cart", &GoCart{Items: GoItems{1, 2, 3}})
+	s.context.SetVar("items", GoItems{4, 5})
+
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			property int cartTotal: cart.items.total()
+			property int itemsTotal: items.total()
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.Int("cartTotal"), Equals, 6)
+	c.Assert(root.Int("itemsTotal"), Equals, 9)
+}
+
+func (s *S) TestSliceMapConversion(c *C) {
+	value := &GoCollections{Strings: []string{"x", "y"}}
+	s.context.SetVar("value", value)
+	s.context.SetVar("nested", map[string]interface{}{"list": []int{1, 2, 3}, "name": "n"})
+
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			property int namesLength: value.names().length
+			property string namesJoined: value.names().join(",")
+			property int stringsLength: value.strings.length
+			property int nestedSecond: nested.list[1]
+			property string nestedName: nested.name
+			Component.onCompleted: {
+				value.items = [1, "a", [true, {k: "v"}]]
+				value.counts = {a: 1, b: 2}
+			}
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.Int("namesLength"), Equals, 3)
+	c.Assert(root.String("namesJoined"), Equals, "a,b,c")
+	c.Assert(root.Int("stringsLength"), Equals, 2)
+	c.Assert(root.Int("nestedSecond"), Equals, 2)
+	c.Assert(root.String("nestedName"), Equals, "n")
+
+	c.Assert(value.Items, DeepEquals, []interface{}{1, "a", []interface{}{true, map[string]interface{}{"k": "v"}}})
+	c.Assert(value.Counts, DeepEquals, map[string]int{"a": 1, "b": 2})
+}
+
+type Serializable interface {
+	Serialize() string
+}
+
+type GoSerializableA struct{ Name string }
+type GoSerializableB struct{ Name string }
+
+func (a *GoSerializableA) Serialize() string { return "A:" + a.Name }
+func (b *GoSerializableB) Serialize() string { return "B:" + b.Name }
+
+func (s *S) TestRegisterGoConverter(c *C) {
+	serializable := reflect.TypeOf((*Serializable)(nil)).Elem()
+	qml.RegisterGoConverter(serializable, func(engine *qml.Engine, value interface{}) interface{} {
+		return value.(Serializable).Serialize()
+	})
+	defer qml.RegisterGoConverter(serializable, nil)
+	qml.RegisterGoConverter(reflect.TypeOf(&GoSerializableB{}), func(engine *qml.Engine, value interface{}) interface{} {
+		return "concrete:" + value.(*GoSerializableB).Name
+	})
+	defer qml.RegisterGoConverter(reflect.TypeOf(&GoSerializableB{}), nil)
+
+	s.context.SetVar("a", &GoSerializableA{"x"})
+	s.context.SetVar("b", &GoSerializableB{"y"})
+	s.context.SetVar("list", []interface{}{&GoSerializableA{"z"}})
+
+	component, err := s.engine.LoadString("file.qml", `
+		import QtQuick 2.0
+		QtObject {
+			property string aText: a
+			property string bText: b
+			property string first: list[0]
+		}
+	`)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("aText"), Equals, "A:x")
+	c.Assert(root.String("bText"), Equals, "concrete:y")
+	c.Assert(root.String("first"), Equals, "A:z")
+}
+
+func (s *S) TestRegisterEnums(c *C) {
+	qml.RegisterTypes("GoEnums", 1, 0, []qml.TypeSpec{{
+		Name: "Task",
+		Init: func(v *GoType, obj qml.Object) {},
+		Enums: []qml.EnumSpec{{
+			Name:   "Status",
+			Values: map[string]int{"Active": 1, "Done": 2},
+		}},
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoEnums 1.0
+		Item {
+			property int active: Task.Active
+			property int done: Task.Done
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.Int("active"), Equals, 1)
+	c.Assert(root.Int("done"), Equals, 2)
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoEnums", 1, 0, []qml.TypeSpec{{
+			Name:  "BadTask",
+			Init:  func(v *GoType, obj qml.Object) {},
+			Enums: []qml.EnumSpec{{Name: "Status", Values: map[string]int{"active": 1}}},
+		}})
+	}, PanicMatches, `enumeration Status has key "active" that does not start with an upper case letter`)
+}
+
+func (s *S) TestRegisterRevisions(c *C) {
+	qml.RegisterTypes("GoRevisions", 1, 0, []qml.TypeSpec{{
+		Init:      func(v *GoType, obj qml.Object) {},
+		Revisions: map[string]int{"intValue": 3},
+	}, {
+		Name:  "GoTypeLater",
+		Init:  func(v *GoType, obj qml.Object) {},
+		Major: 1,
+		Minor: 2,
+	}})
+
+	_, err := s.engine.LoadString("file.qml", "import GoRevisions 1.0\nGoType { stringValue: 'a'; intValue: 1 }")
+	c.Assert(err, ErrorMatches, `(?s).*"intValue".*`)
+
+	component, err := s.engine.LoadString("file.qml", "import GoRevisions 1.3\nGoType { stringValue: 'a'; intValue: 1 }")
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+	c.Assert(root.Int("intValue"), Equals, 1)
+
+	_, err = s.engine.LoadString("file.qml", "import GoRevisions 1.0\nGoTypeLater {}")
+	c.Assert(err, ErrorMatches, `(?s).*GoTypeLater.*`)
+	_, err = s.engine.LoadString("file.qml", "import GoRevisions 1.2\nGoTypeLater {}")
+	c.Assert(err, IsNil)
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoRevisions", 1, 0, []qml.TypeSpec{{
+			Name:      "BadRevisions",
+			Init:      func(v *GoType, obj qml.Object) {},
+			Revisions: map[string]int{"missing": 1},
+		}})
+	}, PanicMatches, `TypeSpec.Revisions refers to unknown member "missing" of \*qml_test.GoType`)
+	c.Assert(func() {
+		qml.RegisterTypes("GoRevisions", 1, 0, []qml.TypeSpec{{
+			Name:      "FarRevisions",
+			Init:      func(v *GoType, obj qml.Object) {},
+			Revisions: map[string]int{"stringValue": 16},
+		}})
+	}, PanicMatches, `TypeSpec.Revisions supports minor versions up to 15, got 16 for member "stringValue"`)
+}
+
+func (s *S) TestRegisterUncreatableType(c *C) {
+	qml.RegisterUncreatableType("GoUncreatable", 1, 0, qml.TypeSpec{
+		Name: "Abstract",
+		Init: func(v *GoType, obj qml.Object) {},
+	}, "<Abstract is not creatable>")
+
+	_, err := s.engine.LoadString("file.qml", "import GoUncreatable 1.0\nAbstract {}")
+	c.Assert(err, ErrorMatches, "(?s).*<Abstract is not creatable>.*")
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoUncreatable", 1, 0, []qml.TypeSpec{{Name: "Abstract", Init: func(v *GoType, obj qml.Object) {}}})
+	}, PanicMatches, `cannot register \*qml_test.GoType as GoUncreatable 1.0 Abstract: already registered as uncreatable \*qml_test.GoType`)
+}
+
+func (s *S) TestRegisterSingleton(c *C) {
+	var engines []*qml.Engine
+	qml.RegisterSingleton("GoSingletonFactory", 1, 0, qml.TypeSpec{Name: "Backend"}, func(engine *qml.Engine) interface{} {
+		engines = append(engines, engine)
+		return &GoType{StringValue: "<factory>"}
+	})
+
+	data := `
+		import QtQuick 2.0
+		import GoSingletonFactory 1.0
+		Item {
+			property string a: Backend.stringValue
+			property string b: Backend.stringValue
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("a"), Equals, "<factory>")
+	c.Assert(root.String("b"), Equals, "<factory>")
+	c.Assert(engines, DeepEquals, []*qml.Engine{s.engine})
+
+	c.Assert(func() {
+		qml.RegisterSingleton("GoSingletonFactory", 1, 0, qml.TypeSpec{Name: "Backend"}, func(engine *qml.Engine) interface{} { return nil })
+	}, PanicMatches, `cannot register singleton factory as GoSingletonFactory 1.0 Backend: name already registered`)
+	c.Assert(func() {
+		qml.RegisterTypes("GoSingletonFactory", 1, 0, []qml.TypeSpec{{Name: "Backend", Init: func(v *GoType, obj qml.Object) {}}})
+	}, PanicMatches, `cannot register \*qml_test.GoType as GoSingletonFactory 1.0 Backend: already registered as a singleton factory`)
+}
+
+func (s *S) TestObjectGoValue(c *C) {
+	qml.RegisterTypes("GoTypesValue", 1, 0, []qml.TypeSpec{{
+		Init: func(v *GoType, obj qml.Object) {},
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoTypesValue 1.0
+		Item { GoType { objectName: "g"; stringValue: "<content>" } }
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	value, ok := root.ObjectByName("g").GoValue()
+	c.Assert(ok, Equals, true)
+	c.Assert(value.(*GoType).StringValue, Equals, "<content>")
+
+	value, ok = root.GoValue()
+	c.Assert(value, IsNil)
+	c.Assert(ok, Equals, false)
+	c.Assert(func() { root.Interface() }, PanicMatches, "QML object is not backed by a Go value")
+}
+
+func (s *S) TestPropertyAt(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			property var info: {"size": {"width": 7}}
+			Item { objectName: "first"; width: 10 }
+			Item {
+				width: 20
+				Item { width: 30 }
+			}
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+
+	value, err := obj.PropertyAt("children[0].objectName")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, "first")
+
+	value, err = obj.PropertyAt("children[1].children[0].width")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 30.0)
+
+	value, err = obj.PropertyAt("info.size.width")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 7)
+
+	c.Assert(obj.SetAt("children[1].children[0].width", 40), IsNil)
+	value, err = obj.PropertyAt("children[1].children[0].width")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 40.0)
+
+	_, err = obj.PropertyAt("children[2].width")
+	c.Assert(err, ErrorMatches, `cannot follow path "children\[2\].width" at children\[2\]: index out of range for list of length 2`)
+	_, err = obj.PropertyAt("children[0].missing")
+	c.Assert(err, ErrorMatches, `cannot follow path "children\[0\].missing" at children\[0\].missing: object does not have a "missing" property`)
+	_, err = obj.PropertyAt("width[0]")
+	c.Assert(err, ErrorMatches, `cannot follow path "width\[0\]" at width\[0\]: value is not a list: .*`)
+	_, err = obj.PropertyAt("children[x]")
+	c.Assert(err, ErrorMatches, `invalid index in property path: "children\[x\]"`)
+	_, err = obj.PropertyAt("a..b")
+	c.Assert(err, ErrorMatches, `invalid property path: "a..b"`)
+
+	err = obj.SetAt("children[0]", 1)
+	c.Assert(err, ErrorMatches, `cannot set list element at path "children\[0\]"`)
+	err = obj.SetAt("children[0].missing", 1)
+	c.Assert(err, ErrorMatches, `.*"missing".*`)
+}
+
+func (s *S) TestMetaIntrospection(c *C) {
+	data := `
+		import QtQuick 2.0
+		QtObject {
+			property string title: "<title>"
+			readonly property int size: 42
+			signal clicked(int button)
+			function greet(name) { return "hello " + name }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+
+	props := make(map[string]qml.PropertyInfo)
+	for _, prop := range obj.MetaProperties() {
+		props[prop.Name] = prop
+	}
+	c.Assert(props["objectName"].Type, Equals, "QString")
+	c.Assert(props["title"], Equals, qml.PropertyInfo{Name: "title", Type: "QString", Writable: true, Notifies: true})
+	c.Assert(props["size"], Equals, qml.PropertyInfo{Name: "size", Type: "int", Writable: false, Notifies: true})
+
+	methods := make(map[string]qml.MethodInfo)
+	for _, method := range obj.MetaMethods() {
+		methods[method.Name] = method
+	}
+	c.Assert(methods["clicked"].Kind, Equals, qml.MethodSignal)
+	c.Assert(methods["clicked"].ParamTypes, DeepEquals, []string{"int"})
+	c.Assert(methods["clicked"].ParamNames, DeepEquals, []string{"button"})
+	c.Assert(methods["greet"].Kind, Not(Equals), qml.MethodSignal)
+	c.Assert(methods["greet"].ParamTypes, DeepEquals, []string{"QVariant"})
+	c.Assert(methods["greet"].ParamNames, DeepEquals, []string{"name"})
+	c.Assert(methods["greet"].ReturnType, Equals, "QVariant")
+}
+
+func (s *S) TestBind(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			property string title: "<initial>"
+			property int count: 1
+			function rename() { title = "<renamed>" }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+
+	var title string
+	unbind := obj.Bind("title", &title)
+	c.Assert(title, Equals, "<initial>")
+
+	obj.Call("rename")
+	c.Assert(title, Equals, "<renamed>")
+
+	unbind()
+	obj.Set("title", "<unbound>")
+	c.Assert(title, Equals, "<renamed>")
+
+	var count int64
+	set, unbind := obj.BindTwoWay("count", &count)
+	defer unbind()
+	c.Assert(count, Equals, int64(1))
+	set(42)
+	c.Assert(count, Equals, int64(42))
+	c.Assert(obj.Int("count"), Equals, 42)
+
+	c.Assert(func() { obj.Bind("missing", &title) }, PanicMatches, `object does not have a "missing" property`)
+	c.Assert(func() { obj.Bind("title", title) }, PanicMatches, `Bind requires a non-nil pointer, got .*`)
+}
+
+func (s *S) TestValueTypes(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			property point p: Qt.point(1.5, 2)
+			property size sz: Qt.size(3, 4.5)
+			property rect r: Qt.rect(1, 2, 3, 4)
+			property color col: "red"
+			property real area: r.width * r.height
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+
+	c.Assert(obj.Property("p"), Equals, qml.Point{X: 1.5, Y: 2})
+	c.Assert(obj.Property("sz"), Equals, qml.Size{Width: 3, Height: 4.5})
+	c.Assert(obj.Property("r"), Equals, qml.Rect{X: 1, Y: 2, Width: 3, Height: 4})
+	c.Assert(obj.Property("col"), Equals, qml.Color{R: 255, G: 0, B: 0, A: 255})
+
+	obj.Set("p", qml.Point{X: 5, Y: 6})
+	obj.Set("sz", qml.Size{Width: 7, Height: 8})
+	obj.Set("r", qml.Rect{X: 0, Y: 0, Width: 10, Height: 20})
+	obj.Set("col", qml.Color{R: 0, G: 0, B: 255, A: 128})
+	c.Assert(obj.Property("p"), Equals, qml.Point{X: 5, Y: 6})
+	c.Assert(obj.Property("sz"), Equals, qml.Size{Width: 7, Height: 8})
+	c.Assert(obj.Property("r"), Equals, qml.Rect{X: 0, Y: 0, Width: 10, Height: 20})
+	c.Assert(obj.Property("col"), Equals, qml.Color{R: 0, G: 0, B: 255, A: 128})
+	c.Assert(obj.Float64("area"), Equals, 200.0)
+
+	obj.Set("col", "lime")
+	c.Assert(obj.Color("col"), Equals, qml.Color{R: 0, G: 255, B: 0, A: 255})
+}
+
+func (s *S) TestParseColor(c *C) {
+	tests := []struct {
+		name  string
+		color qml.Color
+	}{
+		{"red", qml.Color{R: 255, G: 0, B: 0, A: 255}},
+		{"transparent", qml.Color{R: 0, G: 0, B: 0, A: 0}},
+		{"#0f0", qml.Color{R: 0, G: 255, B: 0, A: 255}},
+		{"#102030", qml.Color{R: 0x10, G: 0x20, B: 0x30, A: 0xff}},
+		{"#10203040", qml.Color{R: 0x10, G: 0x20, B: 0x30, A: 0x40}},
+	}
+	for _, test := range tests {
+		col, err := qml.ParseColor(test.name)
+		c.Assert(err, IsNil)
+		c.Assert(col, Equals, test.color)
+		parsed, err := qml.ParseColor(qml.FormatColor(col))
+		c.Assert(err, IsNil)
+		c.Assert(parsed, Equals, col)
+	}
+	c.Assert(qml.FormatColor(qml.Color{R: 0x10, G: 0x20, B: 0x30, A: 0xff}), Equals, "#102030")
+	c.Assert(qml.FormatColor(qml.Color{R: 0x10, G: 0x20, B: 0x30, A: 0x40}), Equals, "#10203040")
+
+	_, err := qml.ParseColor("bogus")
+	c.Assert(err, ErrorMatches, `invalid color name: "bogus"`)
+}
+
+func (s *S) TestWindowOnFileDrop(c *C) {
+	component, err := s.engine.LoadString("file.qml", "import QtQuick 2.0\nItem { width: 100; height: 100 }")
+	c.Assert(err, IsNil)
+	win := component.CreateWindow(nil)
+	defer win.Destroy()
+
+	c.Assert(cpptest.SendFileDrop(&win.Common, []string{"file:///tmp/a.txt"}, 10, 20), Equals, false)
+
+	var dropped []string
+	var x, y int
+	win.OnFileDrop(func(paths []string, dropx, dropy int) {
+		dropped, x, y = paths, dropx, dropy
+	})
+
+	urls := []string{"file:///tmp/a%20b.txt", "http://example.com/c.txt", "file:///tmp/d.txt"}
+	c.Assert(cpptest.SendFileDrop(&win.Common, urls, 10, 20), Equals, true)
+	c.Assert(dropped, DeepEquals, []string{"/tmp/a b.txt", "/tmp/d.txt"})
+	c.Assert(x, Equals, 10)
+	c.Assert(y, Equals, 20)
+
+	dropped = nil
+	c.Assert(cpptest.SendFileDrop(&win.Common, []string{"http://example.com/c.txt"}, 10, 20), Equals, false)
+	c.Assert(dropped, IsNil)
+
+	// Without a function, local files are not taken from DropArea items anymore.
+	win.OnFileDrop(nil)
+	c.Assert(cpptest.SendFileDrop(&win.Common, []string{"file:///tmp/a.txt"}, 10, 20), Equals, false)
+	c.Assert(dropped, IsNil)
+	win.OnFileDrop(nil)
+}
+
+func (s *S) TestComponentStatus(c *C) {
+	component, err := s.engine.LoadString("file.qml", "import QtQuick 2.0\nItem {}")
+	c.Assert(err, IsNil)
+	c.Assert(component.Status(), Equals, qml.ComponentReady)
+	c.Assert(component.WaitReady(context.Background()), IsNil)
+
+	var data []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	data = []byte("import QtQuick 2.0\nItem { width: 42 }")
+	component, err = s.engine.Load(server.URL+"/ok.qml", nil)
+	c.Assert(err, IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Assert(component.WaitReady(ctx), IsNil)
+	c.Assert(component.Status(), Equals, qml.ComponentReady)
+	obj := component.Create(nil)
+	defer obj.Destroy()
+	c.Assert(obj.Int("width"), Equals, 42)
+
+	data = []byte("import QtQuick 2.0\nItem { bad: 1 }")
+	component, err = s.engine.Load(server.URL+"/bad.qml", nil)
+	if err == nil {
+		err = component.WaitReady(ctx)
+		c.Assert(component.Status(), Equals, qml.ComponentError)
+	}
+	c.Assert(err, ErrorMatches, `.*/bad.qml:2 Cannot assign to non-existent property "bad"`)
+}
+
+func (s *S) TestApplicationInfo(c *C) {
+	c.Assert(qml.QtVersion(), Matches, `5\.\d+\.\d+.*`)
+	c.Assert(func() { qml.SetApplication("name", "org", "1.0") }, PanicMatches, "qml.SetApplication must be called before qml.Run")
+}
+
+func (s *S) TestSetGraphicsBackendAfterRun(c *C) {
+	err := qml.SetGraphicsBackend(qml.SoftwareBackend)
+	c.Assert(err, ErrorMatches, "graphics backend must be set before qml.Run is called")
+}
+
+func (s *S) TestRunContext(c *C) {
+	runTestMain(c, "RunContext")
+}
+
+// runContextMain cancels the context given to RunContext from within the
+// function it runs, and checks that RunContext waits for the function to
+// clean up and leaves no goroutines behind.
+func runContextMain() error {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cleaned := false
+	err := qml.RunContext(ctx, func() error {
+		defer func() { cleaned = true }()
+		engine := qml.NewEngine()
+		defer engine.Destroy()
+		cancel()
+		<-ctx.Done()
+		// Functions sent to RunMain still run once the loop terminates.
+		qml.RunMain(func() {})
+		return ctx.Err()
+	})
+	if err != context.Canceled {
+		return fmt.Errorf("RunContext returned %v, want %v", err, context.Canceled)
+	}
+	if !cleaned {
+		return fmt.Errorf("RunContext returned before the function cleaned up")
+	}
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			return fmt.Errorf("RunContext left %d goroutines running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func (s *S) TestRunOffscreen(c *C) {
+	// RunOffscreen must select the platform and backend by itself.
+	runTestMain(c, "RunOffscreen", "QT_QPA_PLATFORM=", "QT_QUICK_BACKEND=")
+}
+
+// runOffscreenMain shows a window under RunOffscreen and checks that its
+// content may be grabbed.
+func runOffscreenMain() error {
+	return qml.RunOffscreen(func() error {
+		if platform := os.Getenv("QT_QPA_PLATFORM"); platform != "offscreen" {
+			return fmt.Errorf("RunOffscreen set QT_QPA_PLATFORM to %q", platform)
+		}
+		if backend := os.Getenv("QT_QUICK_BACKEND"); backend != "software" {
+			return fmt.Errorf("RunOffscreen set QT_QUICK_BACKEND to %q", backend)
+		}
+		engine := qml.NewEngine()
+		defer engine.Destroy()
+		component, err := engine.LoadString("file.qml", `
+			import QtQuick 2.0
+			Rectangle { width: 100; height: 50; color: "#ff0000" }
+		`)
+		if err != nil {
+			return err
+		}
+		win := component.CreateWindow(nil)
+		defer win.Destroy()
+		win.Show()
+		var img image.Image
+		for i := 0; i < 50; i++ {
+			img, err = win.Grab()
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			return err
+		}
+		if size := img.Bounds().Size(); size != image.Pt(100, 50) {
+			return fmt.Errorf("grabbed image has size %v, want 100x50", size)
+		}
+		if center := img.At(50, 25); center != (color.RGBA{255, 0, 0, 255}) {
+			return fmt.Errorf("grabbed image has %v at its center, want red", center)
+		}
+		return nil
+	})
+}
+
+func (s *S) TestEngineSetNetworkConfig(c *C) {
+	var requests = make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.Write([]byte("import QtQuick 2.0\nItem {}"))
+	}))
+	defer server.Close()
+
+	engine := qml.NewEngine()
+	defer engine.Destroy()
+
+	err := engine.SetNetworkConfig(qml.NetworkConfig{
+		Headers: http.Header{"Authorization": {"Bearer <token>"}},
+		Proxy:   server.URL,
+	})
+	c.Assert(err, IsNil)
+
+	// The server acts as the proxy for a host that doesn't exist.
+	component, err := engine.Load("http://example.invalid/file.qml", nil)
+	c.Assert(err, IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Assert(component.WaitReady(ctx), IsNil)
+
+	r := <-requests
+	c.Assert(r.Header.Get("Authorization"), Equals, "Bearer <token>")
+	c.Assert(r.URL.Host, Equals, "example.invalid")
+
+	err = engine.SetNetworkConfig(qml.NetworkConfig{Proxy: "ftp://proxy"})
+	c.Assert(err, ErrorMatches, `unsupported proxy URL scheme: "ftp"`)
+	err = engine.SetNetworkConfig(qml.NetworkConfig{CACerts: []byte("bogus")})
+	c.Assert(err, ErrorMatches, "no certificates found in CA bundle")
+}
+
+func (s *S) TestCreateWithProperties(c *C) {
+	data := `
+		import QtQuick 2.0
+		Item {
+			property int a: 1
+			property int b: 2
+			property int sum: a + b
+			property int completedSum
+			Component.onCompleted: completedSum = sum
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+
+	obj, err := component.CreateWithProperties(nil, map[string]interface{}{"a": 10, "b": 20})
+	c.Assert(err, IsNil)
+	defer obj.Destroy()
+
+	c.Assert(obj.Int("sum"), Equals, 30)
+	c.Assert(obj.Int("completedSum"), Equals, 30)
+
+	obj, err = component.CreateWithProperties(nil, nil)
+	c.Assert(err, IsNil)
+	defer obj.Destroy()
+	c.Assert(obj.Int("completedSum"), Equals, 3)
+}
+
+type Money struct {
+	Currency string
+	Cents    int
+}
+
+type Wallet struct {
+	Balance Money
+}
+
+func (w *Wallet) Describe(m Money) string {
+	return fmt.Sprintf("%s %d", m.Currency, m.Cents)
+}
+
+func (s *S) TestRegisterValueType(c *C) {
+	qml.RegisterValueType(qml.TypeSpec{
+		Init: func(m *Money) { m.Currency = "USD" },
+	})
+	var wallet *Wallet
+	qml.RegisterTypes("GoValueTypes", 1, 0, []qml.TypeSpec{{
+		Init: func(w *Wallet, obj qml.Object) {
+			w.Balance = Money{"EUR", 100}
+			wallet = w
+		},
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoValueTypes 1.0
+		Wallet {
+			property string label: balance.currency + " " + balance.cents
+			function spend() { var b = balance; b.cents -= 50; return balance.cents }
+			function deposit() { balance = {cents: 250} }
+			function describeCoins() { return describe({currency: "BRL", cents: 7}) }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	c.Assert(root.String("label"), Equals, "EUR 100")
+
+	// Values are copies, so changing them in QML has no effect.
+	c.Assert(root.Call("spend"), Equals, 100)
+	c.Assert(wallet.Balance, Equals, Money{"EUR", 100})
+
+	// Assigned objects start from a value prepared by Init.
+	root.Call("deposit")
+	c.Assert(wallet.Balance, Equals, Money{"USD", 250})
+	c.Assert(root.Call("describeCoins"), Equals, "BRL 7")
+
+	c.Assert(func() {
+		qml.RegisterValueType(qml.TypeSpec{Init: func(m *Money, obj qml.Object) {}})
+	}, PanicMatches, `TypeSpec.Init's function for a value type must take a single pointer to a struct: func\(\*qml_test.Money, qml.Object\)`)
+	c.Assert(func() {
+		qml.RegisterValueType(qml.TypeSpec{Init: func(m *Money) {}, Name: "Money"})
+	}, PanicMatches, `value types only support the TypeSpec.Init field`)
+}
+
+func (s *S) TestCallAsync(c *C) {
+	qml.RegisterTypes("GoTypesValue", 1, 0, []qml.TypeSpec{{
+		Init: func(v *GoType, obj qml.Object) {},
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoTypesValue 1.0
+		Item {
+			function add(a, b) { return a + b }
+			function fail() { throw new Error("<failure>") }
+			GoType { objectName: "g" }
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	outcomes := make(chan outcome, 1)
+	done := func(result interface{}, err error) {
+		outcomes <- outcome{result, err}
+	}
+
+	root.CallAsync("add", done, 1, 2)
+	o := <-outcomes
+	c.Assert(o.err, IsNil)
+	c.Assert(o.result, Equals, 3)
+
+	root.CallAsync("fail", done)
+	o = <-outcomes
+	c.Assert(o.err, ErrorMatches, "(?s).*<failure>.*")
+
+	g := root.ObjectByName("g")
+	g.CallAsync("mod", done, 7, 4)
+	o = <-outcomes
+	c.Assert(o.err, IsNil)
+	c.Assert(o.result, Equals, 3)
+
+	g.CallAsync("mod", done, 7, 0)
+	o = <-outcomes
+	c.Assert(o.err, ErrorMatches, "<division by zero>")
+
+	// Calls to QML methods are ordered with functions scheduled via Go.
+	var order []string
+	finished := make(chan bool)
+	root.CallAsync("add", func(result interface{}, err error) { order = append(order, "call") }, 1, 2)
+	qml.Go(func() {
+		order = append(order, "go")
+		finished <- true
+	})
+	<-finished
+	c.Assert(order, DeepEquals, []string{"call", "go"})
+}
+
+type GoContainer struct {
+	Items []qml.Object
+}
+
+func (s *S) TestDefaultProperty(c *C) {
+	qml.RegisterTypes("GoContainers", 1, 0, []qml.TypeSpec{{
+		Init:            func(v *GoContainer, obj qml.Object) {},
+		DefaultProperty: "items",
+	}})
+
+	data := `
+		import QtQuick 2.0
+		import GoContainers 1.0
+		GoContainer {
+			Item { objectName: "a" }
+			Item { objectName: "b" }
+			property int count: items.length
+		}
+	`
+	component, err := s.engine.LoadString("file.qml", data)
+	c.Assert(err, IsNil)
+	root := component.Create(nil)
+	defer root.Destroy()
+
+	value, ok := root.GoValue()
+	c.Assert(ok, Equals, true)
+	items := value.(*GoContainer).Items
+	c.Assert(items, HasLen, 2)
+	c.Assert(items[0].String("objectName"), Equals, "a")
+	c.Assert(items[1].String("objectName"), Equals, "b")
+	c.Assert(root.Int("count"), Equals, 2)
+
+	c.Assert(func() {
+		qml.RegisterTypes("GoContainers", 1, 0, []qml.TypeSpec{{
+			Name:            "BadContainer",
+			Init:            func(v *GoContainer, obj qml.Object) {},
+			DefaultProperty: "missing",
+		}})
+	}, PanicMatches, `TypeSpec.DefaultProperty refers to unknown property "missing" of \*qml_test.GoContainer`)
+}
+
 var tablef = flag.String("tablef", "", "if provided, TestTable only runs tests with a summary matching the regexp")
 
 func (s *S) TestTable(c *C) {
@@ -2,9 +2,18 @@ package qml
 
 import (
 	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
 	"sort"
 	"strings"
+	"sync"
+	"unicode/utf16"
 )
 
 // ParseResources parses the resources collection serialized in data.
@@ -17,6 +26,7 @@ func ParseResources(data []byte) (*Resources, error) {
 		return nil, err
 	}
 	r.bdata = data
+	r.readExtension()
 	return r, nil
 }
 
@@ -30,6 +40,7 @@ func ParseResourcesString(data string) (*Resources, error) {
 		return nil, err
 	}
 	r.sdata = data
+	r.readExtension()
 	return r, nil
 }
 
@@ -52,10 +63,27 @@ func parseResourcesHeader(h []byte, size int) (*Resources, error) {
 	return r, nil
 }
 
+// readExtension loads the details held in the extension block that
+// follows the standard header in resources packed by this package.
+// Qt only follows the offsets in the header, so it skips the block.
+func (r *Resources) readExtension() {
+	if r.dataOffset < resHeaderSize+resExtSize {
+		return
+	}
+	ext := r.slice(resHeaderSize, resExtSize)
+	if string(ext[:4]) == resExtMagic && read32(ext[4:]) == resExtVersion {
+		r.hash = hex.EncodeToString(ext[8:])
+	}
+}
+
 func read32(b []byte) int {
 	return int(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]))
 }
 
+func read16(b []byte) int {
+	return int(uint16(b[0])<<8 | uint16(b[1]))
+}
+
 // Resources is a compact representation of a collection of resources
 // (images, qml files, etc) that may be loaded by an Engine and referenced
 // by QML at "qrc:///some/path", where "some/path" is the path the
@@ -70,6 +98,9 @@ type Resources struct {
 	treeOffset int
 	dataOffset int
 	nameOffset int
+
+	hash     string
+	hashOnce sync.Once
 }
 
 // Bytes returns a binary representation of the resources collection that
@@ -81,29 +112,274 @@ func (r *Resources) Bytes() []byte {
 	return r.bdata
 }
 
+// ResourceInfo holds details about a resource in a Resources collection.
+type ResourceInfo struct {
+	Path string // The resource is available under "qrc:///"+Path.
+	Size int    // The uncompressed size of the resource data.
+}
+
+// List returns details about all resources in r, sorted by path.
+func (r *Resources) List() []ResourceInfo {
+	var list []ResourceInfo
+	r.walk(0, "", func(path string, node *resNode) {
+		list = append(list, ResourceInfo{Path: path, Size: r.dataSize(node)})
+	})
+	sort.Sort(resInfos(list))
+	return list
+}
+
+// Contains reports whether r holds a resource at the given path.
+// The path may be provided either as used with ResourcesPacker.Add
+// or as a "qrc:///some/path" URL.
+func (r *Resources) Contains(path string) bool {
+	node := r.lookup(path)
+	return node != nil && node.flags&resDirectory == 0
+}
+
+// Hash returns a hex-encoded SHA-256 digest of the paths and data of all
+// resources in r. The digest depends only on the resources themselves, so
+// it does not change with the compression level used when packing them.
+//
+// The hash is computed when the resources are packed and stored with them.
+// For resources packed by earlier versions of this package, it's computed
+// when first requested instead, and is empty if some resource data cannot
+// be read.
+func (r *Resources) Hash() string {
+	r.hashOnce.Do(func() {
+		if r.hash != "" {
+			return
+		}
+		var sums []resSum
+		for _, info := range r.List() {
+			data, err := r.ReadFile(info.Path)
+			if err != nil {
+				return
+			}
+			sums = append(sums, resSum{info.Path, len(data), sha256.Sum256(data)})
+		}
+		r.hash = hex.EncodeToString(resHash(sums))
+	})
+	return r.hash
+}
+
+// resSum holds the details of a resource that are covered by its hash.
+type resSum struct {
+	path string
+	size int
+	sum  [sha256.Size]byte
+}
+
+type resSums []resSum
+
+func (rs resSums) Len() int           { return len(rs) }
+func (rs resSums) Less(i, j int) bool { return rs[i].path < rs[j].path }
+func (rs resSums) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+
+// resHash returns the hash of resources with the provided sums.
+func resHash(sums []resSum) []byte {
+	sort.Sort(resSums(sums))
+	h := sha256.New()
+	for _, s := range sums {
+		fmt.Fprintf(h, "%s\x00%d\x00", s.path, s.size)
+		h.Write(s.sum[:])
+	}
+	return h.Sum(nil)
+}
+
+// ReadFile returns the data of the resource at the given path, which may
+// be provided as for Contains. Compressed resources are decompressed.
+// If there is no resource at path, the returned error satisfies os.IsNotExist.
+func (r *Resources) ReadFile(path string) ([]byte, error) {
+	node := r.lookup(path)
+	if node == nil || node.flags&resDirectory != 0 {
+		return nil, &os.PathError{Op: "read", Path: path, Err: os.ErrNotExist}
+	}
+	offset := r.dataOffset + node.dataOffset
+	data := r.slice(offset+4, read32(r.slice(offset, 4)))
+	if node.flags&resCompressed == 0 {
+		return append([]byte(nil), data...), nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[4:]))
+	if err == nil {
+		data, err = ioutil.ReadAll(zr)
+	}
+	if err == nil && len(data) != r.dataSize(node) {
+		err = fmt.Errorf("expected %d bytes, got %d", r.dataSize(node), len(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress resource %s: %v", path, err)
+	}
+	return data, nil
+}
+
+type resInfos []ResourceInfo
+
+func (ri resInfos) Len() int           { return len(ri) }
+func (ri resInfos) Less(i, j int) bool { return ri[i].Path < ri[j].Path }
+func (ri resInfos) Swap(i, j int)      { ri[i], ri[j] = ri[j], ri[i] }
+
+// resNode holds the details of an entry in the serialized resources tree.
+type resNode struct {
+	name  string
+	flags int
+
+	// For directories.
+	count int
+	child int
+
+	// For files.
+	dataOffset int
+}
+
+// slice returns size bytes of r's serialized data, starting at offset.
+func (r *Resources) slice(offset, size int) []byte {
+	if len(r.sdata) > 0 {
+		return []byte(r.sdata[offset : offset+size])
+	}
+	return r.bdata[offset : offset+size]
+}
+
+// node returns the entry at the given index of the resources tree.
+func (r *Resources) node(index int) *resNode {
+	b := r.slice(r.treeOffset+index*resNodeSize, resNodeSize)
+	node := &resNode{
+		name:  r.name(read32(b)),
+		flags: read16(b[4:]),
+	}
+	if node.flags&resDirectory != 0 {
+		node.count = read32(b[6:])
+		node.child = read32(b[10:])
+	} else {
+		node.dataOffset = read32(b[10:])
+	}
+	return node
+}
+
+// name returns the entry name at the given offset of the names section.
+func (r *Resources) name(offset int) string {
+	offset += r.nameOffset
+	n := read16(r.slice(offset, 2))
+	b := r.slice(offset+6, n*2)
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = uint16(read16(b[i*2:]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// walk calls f for every file under the directory at the given index of
+// the resources tree, with dir being the path of that directory.
+func (r *Resources) walk(index int, dir string, f func(path string, node *resNode)) {
+	node := r.node(index)
+	for i := 0; i < node.count; i++ {
+		child := r.node(node.child + i)
+		childPath := path.Join(dir, child.name)
+		if child.flags&resDirectory != 0 {
+			r.walk(node.child+i, childPath, f)
+		} else {
+			f(childPath, child)
+		}
+	}
+}
+
+// lookup returns the entry at the given path of the resources tree,
+// or nil if there is none.
+func (r *Resources) lookup(rpath string) *resNode {
+	rpath = strings.TrimPrefix(rpath, "qrc:")
+	rpath = strings.TrimPrefix(path.Clean("/"+rpath), "/")
+	node := r.node(0)
+	if rpath == "" {
+		return node
+	}
+	for _, name := range strings.Split(rpath, "/") {
+		if node.flags&resDirectory == 0 {
+			return nil
+		}
+		found := false
+		for i := 0; i < node.count; i++ {
+			child := r.node(node.child + i)
+			if child.name == name {
+				node, found = child, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return node
+}
+
+// dataSize returns the uncompressed size of the file node data.
+func (r *Resources) dataSize(node *resNode) int {
+	offset := r.dataOffset + node.dataOffset
+	if node.flags&resCompressed != 0 {
+		// Compressed data is prefixed by its uncompressed size.
+		return read32(r.slice(offset+4, 4))
+	}
+	return read32(r.slice(offset, 4))
+}
+
 // ResourcesPacker builds a Resources collection with provided resources.
 type ResourcesPacker struct {
 	root resFile
+
+	compression int
+	aliases     map[string]string
+}
+
+// SetCompression sets the zlib compression level used by Pack, as defined
+// in the compress/zlib package. Compressed resources are transparently
+// decompressed by the QML engine when first accessed. Resources that do not
+// shrink significantly when compressed, such as PNG images, are stored as-is.
+// The default level of zero disables compression.
+func (rp *ResourcesPacker) SetCompression(level int) {
+	if _, err := zlib.NewWriterLevel(nil, level); err != nil {
+		panic(fmt.Sprintf("invalid resources compression level: %d", level))
+	}
+	rp.compression = level
 }
 
 // Pack builds a resources collection with all resources previously added.
+// Pack panics if reading a resource added with AddReader fails. Use WriteTo
+// to handle such errors instead.
 func (rp *ResourcesPacker) Pack() *Resources {
-	rw := newResourcesWriter(rp)
+	var buf bytes.Buffer
+	rw := newResourcesWriter(rp, &buf)
 	rw.write()
+	if rw.err != nil {
+		panic(rw.err)
+	}
 	return &Resources{
-		bdata:      rw.out.Bytes(),
+		bdata:      buf.Bytes(),
 		version:    resVersion,
 		dataOffset: rw.dataOffset,
 		nameOffset: rw.nameOffset,
 		treeOffset: rw.treeOffset,
+		hash:       hex.EncodeToString(rw.hash),
 	}
 }
 
+// WriteTo writes the serialized resources collection with all resources
+// previously added to w, in the same format returned by Pack().Bytes(),
+// without holding the whole serialized data in memory.
+func (rp *ResourcesPacker) WriteTo(w io.Writer) (n int64, err error) {
+	rw := newResourcesWriter(rp, w)
+	rw.write()
+	return rw.n, rw.err
+}
+
 type resFile struct {
 	name  string
 	sdata string
 	bdata []byte
 
+	// For resources added with AddReader. If r is an io.Seeker,
+	// offset is where its data starts.
+	path   string
+	r      io.Reader
+	offset int64
+
 	children resFiles
 }
 
@@ -119,6 +395,72 @@ func (rp *ResourcesPacker) AddString(path, data string) {
 	file.sdata = data
 }
 
+// AddReader adds a resource with the data read from r until EOF under
+// "qrc:///"+path. The data is only read when the resources are packed by
+// Pack or WriteTo, one resource at a time, so r must remain readable until
+// then. If r is an io.Seeker, it is rewound and read again whenever its data
+// is needed; otherwise the data is held in memory by rp once read.
+// An error is returned if path is already in use.
+func (rp *ResourcesPacker) AddReader(path string, r io.Reader) error {
+	if rp.findFile(path) != nil {
+		return fmt.Errorf("cannot add resource %s: path already in use", path)
+	}
+	var offset int64
+	if seeker, ok := r.(io.Seeker); ok {
+		var err error
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return fmt.Errorf("cannot read resource %s: %v", path, err)
+		}
+	}
+	file := rp.addFile(path)
+	file.path = path
+	file.r = r
+	file.offset = offset
+	return nil
+}
+
+// AddWithAlias adds a resource with the provided data under "qrc:///"+alias.
+// The label identifies where the resource came from, such as its original
+// path, and is used to report conflicts. Unlike Add, AddWithAlias returns
+// an error rather than panicking if alias is already in use.
+func (rp *ResourcesPacker) AddWithAlias(label, alias string, data []byte) error {
+	key := strings.TrimPrefix(alias, "/")
+	if prev, ok := rp.aliases[key]; ok {
+		return fmt.Errorf("cannot add %s as %s: already used by %s", label, alias, prev)
+	}
+	if rp.findFile(key) != nil {
+		return fmt.Errorf("cannot add %s as %s: path already in use", label, alias)
+	}
+	if rp.aliases == nil {
+		rp.aliases = make(map[string]string)
+	}
+	rp.aliases[key] = label
+	rp.Add(alias, data)
+	return nil
+}
+
+// findFile returns the file or directory added under path, or nil if
+// there is none.
+func (rp *ResourcesPacker) findFile(path string) *resFile {
+	file := &rp.root
+	names := strings.Split(path, "/")
+	if len(names[0]) == 0 {
+		names = names[1:]
+	}
+NextItem:
+	for _, name := range names {
+		for i := range file.children {
+			child := &file.children[i]
+			if child.name == name {
+				file = child
+				continue NextItem
+			}
+		}
+		return nil
+	}
+	return file
+}
+
 func (rp *ResourcesPacker) addFile(path string) *resFile {
 	file := &rp.root
 	names := strings.Split(path, "/")
@@ -137,14 +479,15 @@ NextItem:
 		file.children = append(file.children, resFile{name: name})
 		file = &file.children[len(file.children)-1]
 	}
-	if len(file.children) > 0 || file.sdata != "" || file.bdata != nil {
+	if len(file.children) > 0 || file.sdata != "" || file.bdata != nil || file.r != nil {
 		panic("cannot add same resources path twice: " + path)
 	}
 	return file
 }
 
 type resWriter struct {
-	root *resFile
+	root        *resFile
+	compression int
 
 	treeOffset int
 	dataOffset int
@@ -153,18 +496,41 @@ type resWriter struct {
 	treeOffsets map[*resFile]int
 	dataOffsets map[*resFile]int
 	nameOffsets map[string]int
+	compressed  map[*resFile]bool
+	blobs       map[[sha256.Size]byte]*resFile
+
+	// Data of blob files computed during layout and held until written,
+	// such as compressed data, and the size of the remaining blob files.
+	blobData  map[*resFile][]byte
+	blobSizes map[*resFile]int
+
+	// Files with data and names to be written, in order.
+	blobFiles []*resFile
+	names     []string
+
+	// The hash of all resources, stored in the extension block.
+	hash []byte
 
 	pending []*resFile
-	out     bytes.Buffer
+
+	w   io.Writer
+	n   int64
+	err error
 }
 
-func newResourcesWriter(rp *ResourcesPacker) *resWriter {
+func newResourcesWriter(rp *ResourcesPacker, w io.Writer) *resWriter {
 	rw := &resWriter{
 		root:        &rp.root,
+		compression: rp.compression,
 		treeOffsets: make(map[*resFile]int),
 		dataOffsets: make(map[*resFile]int),
 		nameOffsets: make(map[string]int),
+		compressed:  make(map[*resFile]bool),
+		blobs:       make(map[[sha256.Size]byte]*resFile),
+		blobData:    make(map[*resFile][]byte),
+		blobSizes:   make(map[*resFile]int),
 		pending:     make([]*resFile, maxPending(&rp.root)),
+		w:           w,
 	}
 
 	pending := rw.pending
@@ -196,26 +562,35 @@ func maxPending(file *resFile) int {
 }
 
 func (rw *resWriter) write() {
+	rw.layout()
+	if rw.err != nil {
+		return
+	}
 	rw.writeHeader()
 	rw.writeDataBlobs()
 	rw.writeDataNames()
 	rw.writeDataTree()
-	rw.finishHeader()
 }
 
-func (rw *resWriter) writeHeader() {
-	rw.out.WriteString("qres\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
-}
+const resHeaderSize = 20
 
-func (rw *resWriter) finishHeader() {
-	rw.write32at(4, resVersion)
-	rw.write32at(8, uint32(rw.treeOffset))
-	rw.write32at(12, uint32(rw.dataOffset))
-	rw.write32at(16, uint32(rw.nameOffset))
-}
+// Resources packed by this package hold an extension block between the
+// standard header and the data section. It starts with resExtMagic and
+// resExtVersion, followed by the SHA-256 hash of the resources.
+const (
+	resExtMagic   = "goqr"
+	resExtVersion = 1
+	resExtSize    = 8 + sha256.Size
+)
 
-func (rw *resWriter) writeDataBlobs() {
-	rw.dataOffset = rw.out.Len()
+// layout computes the offsets of all data blobs and names, and of the
+// sections holding them, and the hash of all resources, so that the
+// header may be written out first.
+func (rw *resWriter) layout() {
+	dataSize := 0
+	nameSize := 0
+	dirs := map[*resFile]string{rw.root: ""}
+	var sums []resSum
 	pending := rw.pending
 	pending[0] = rw.root
 	n := 1
@@ -224,60 +599,192 @@ func (rw *resWriter) writeDataBlobs() {
 		file := pending[n]
 		for i := range file.children {
 			child := &file.children[i]
+			childPath := path.Join(dirs[file], child.name)
 			if len(child.children) > 0 {
+				dirs[child] = childPath
 				pending[n] = child
 				n++
+			} else if data, err := rw.fileData(child); err != nil {
+				rw.err = err
+				return
 			} else {
-				rw.dataOffsets[child] = rw.out.Len() - rw.dataOffset
-				rw.writeDataBlob(child)
+				sum := sha256.Sum256(data)
+				sums = append(sums, resSum{childPath, len(data), sum})
+				if same := rw.sameBlob(child, sum); same != nil {
+					// Identical content is stored only once.
+					rw.dataOffsets[child] = rw.dataOffsets[same]
+					rw.compressed[child] = rw.compressed[same]
+				} else {
+					rw.dataOffsets[child] = dataSize
+					rw.blobFiles = append(rw.blobFiles, child)
+					dataSize += 4 + rw.blobSize(child, data)
+				}
 			}
+			if _, ok := rw.nameOffsets[child.name]; !ok {
+				rw.nameOffsets[child.name] = nameSize
+				rw.names = append(rw.names, child.name)
+				nameSize += 6 + 2*len(utf16.Encode([]rune(child.name)))
+			}
+		}
+	}
+	rw.hash = resHash(sums)
+	rw.dataOffset = resHeaderSize + resExtSize
+	rw.nameOffset = rw.dataOffset + dataSize
+	rw.treeOffset = rw.nameOffset + nameSize
+}
+
+func (rw *resWriter) writeHeader() {
+	rw.writeString("qres")
+	rw.write32(resVersion)
+	rw.write32(uint32(rw.treeOffset))
+	rw.write32(uint32(rw.dataOffset))
+	rw.write32(uint32(rw.nameOffset))
+	rw.writeString(resExtMagic)
+	rw.write32(resExtVersion)
+	rw.writeBytes(rw.hash)
+}
+
+func (rw *resWriter) writeDataBlobs() {
+	for _, file := range rw.blobFiles {
+		rw.writeDataBlob(file)
+	}
+}
+
+// fileData returns the data of file. Data added with AddReader is read
+// from the reader, which is first rewound if it's an io.Seeker. Otherwise
+// the reader may only be read once, so its data is kept with the file.
+func (rw *resWriter) fileData(file *resFile) ([]byte, error) {
+	if file.r == nil {
+		if len(file.sdata) > 0 {
+			return []byte(file.sdata), nil
+		}
+		return file.bdata, nil
+	}
+	if err := file.rewind(); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(file.r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read resource %s: %v", file.path, err)
+	}
+	if _, ok := file.r.(io.Seeker); !ok {
+		file.bdata = data
+		file.r = nil
+	}
+	return data, nil
+}
+
+// rewind moves the reader of a file added with AddReader back to the
+// start of its data, if it's an io.Seeker.
+func (file *resFile) rewind() error {
+	if seeker, ok := file.r.(io.Seeker); ok {
+		if _, err := seeker.Seek(file.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("cannot read resource %s: %v", file.path, err)
 		}
 	}
+	return nil
+}
+
+// sameBlob returns a previously seen file with the same content as file,
+// as identified by its SHA-256 sum, or nil if there is none.
+func (rw *resWriter) sameBlob(file *resFile, sum [sha256.Size]byte) *resFile {
+	if same, ok := rw.blobs[sum]; ok {
+		return same
+	}
+	rw.blobs[sum] = file
+	return nil
+}
+
+// blobSize returns the size of the file data as it will be written out,
+// and decides whether the data is compressed. Compressed data is held
+// until written, so that each file is compressed only once.
+func (rw *resWriter) blobSize(file *resFile, data []byte) int {
+	if rw.compression != zlib.NoCompression {
+		if cdata, ok := resCompress(data, rw.compression); ok {
+			rw.compressed[file] = true
+			rw.blobData[file] = cdata
+			return len(cdata)
+		}
+	}
+	rw.blobSizes[file] = len(data)
+	return len(data)
 }
 
 func (rw *resWriter) writeDataBlob(file *resFile) {
+	if cdata, ok := rw.blobData[file]; ok {
+		rw.write32(uint32(len(cdata)))
+		rw.writeBytes(cdata)
+		return
+	}
+	if file.r != nil {
+		// Stream the data again rather than holding it since layout.
+		size := rw.blobSizes[file]
+		rw.write32(uint32(size))
+		if rw.err == nil {
+			rw.err = file.rewind()
+		}
+		if rw.err == nil {
+			// Read until EOF rather than just size bytes, so that readers
+			// holding resources such as open files may release them.
+			n, err := io.Copy(rw.w, file.r)
+			if err == nil && n != int64(size) {
+				err = fmt.Errorf("cannot read resource %s: data changed while packing", file.path)
+			}
+			rw.n += n
+			rw.err = err
+		}
+		return
+	}
 	if len(file.sdata) > 0 {
 		rw.write32(uint32(len(file.sdata)))
-		rw.out.WriteString(file.sdata)
+		rw.writeString(file.sdata)
 	} else {
 		rw.write32(uint32(len(file.bdata)))
-		rw.out.Write(file.bdata)
+		rw.writeBytes(file.bdata)
 	}
 }
 
 func (rw *resWriter) writeDataNames() {
-	rw.nameOffset = rw.out.Len()
-	pending := rw.pending
-	pending[0] = rw.root
-	n := 1
-	for n > 0 {
-		n--
-		file := pending[n]
-		for i := range file.children {
-			child := &file.children[i]
-			if len(child.children) > 0 {
-				pending[n] = child
-				n++
-			}
-			if _, ok := rw.nameOffsets[child.name]; !ok {
-				rw.nameOffsets[child.name] = rw.out.Len() - rw.nameOffset
-				rw.writeDataName(child.name)
-			}
-		}
+	for _, name := range rw.names {
+		rw.writeDataName(name)
+	}
+}
+
+// resCompressThreshold is the minimum percentage of space that must be
+// saved by compressing a resource for the compressed data to be used.
+const resCompressThreshold = 10
+
+// resCompress returns data compressed in the format understood by Qt's
+// qUncompress: the uncompressed length as a big-endian 32 bits integer
+// followed by the zlib stream. The returned flag reports whether the
+// compressed data is worth storing in place of the original one.
+func resCompress(data []byte, level int) (cdata []byte, ok bool) {
+	var buf bytes.Buffer
+	n := len(data)
+	buf.Write([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	w, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		panic(err)
 	}
+	w.Write(data)
+	w.Close()
+	if buf.Len()*100 > n*(100-resCompressThreshold) {
+		return nil, false
+	}
+	return buf.Bytes(), true
 }
 
 func (rw *resWriter) writeDataName(name string) {
-	rw.write16(uint16(len(name)))
+	// The length is in UTF-16 units, not bytes.
+	units := utf16.Encode([]rune(name))
+	rw.write16(uint16(len(units)))
 	rw.write32(qt_hash(name))
-	for _, r := range name {
-		rw.write16(uint16(r))
+	for _, u := range units {
+		rw.write16(u)
 	}
 }
 
 func (rw *resWriter) writeDataTree() {
-	rw.treeOffset = rw.out.Len()
-
 	// Compute first child offset for each parent.
 	pending := rw.pending
 	pending[0] = rw.root
@@ -322,7 +829,11 @@ func (rw *resWriter) writeDataInfo(file *resFile) {
 		rw.write32(uint32(len(file.children)))
 		rw.write32(uint32(rw.treeOffsets[file]))
 	} else {
-		rw.write16(uint16(resNone))
+		if rw.compressed[file] {
+			rw.write16(uint16(resCompressed))
+		} else {
+			rw.write16(uint16(resNone))
+		}
 		rw.write16(0) // QLocale::AnyCountry
 		rw.write16(1) // QLocale::C
 		rw.write32(uint32(rw.dataOffsets[file]))
@@ -330,7 +841,8 @@ func (rw *resWriter) writeDataInfo(file *resFile) {
 }
 
 const (
-	resVersion = 1
+	resVersion  = 1
+	resNodeSize = 14
 
 	resNone       = 0
 	resCompressed = 1
@@ -338,26 +850,44 @@ const (
 )
 
 func (rw *resWriter) write16(v uint16) {
-	rw.out.Write([]byte{byte(v >> 8), byte(v)})
+	rw.writeBytes([]byte{byte(v >> 8), byte(v)})
 }
 
 func (rw *resWriter) write32(v uint32) {
-	rw.out.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	rw.writeBytes([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
 }
 
-func (rw *resWriter) write32at(index int, v uint32) {
-	b := rw.out.Bytes()
-	b[index+0] = byte(v >> 24)
-	b[index+1] = byte(v >> 16)
-	b[index+2] = byte(v >> 8)
-	b[index+3] = byte(v)
+// writeBytes writes b out unless a previous write failed.
+func (rw *resWriter) writeBytes(b []byte) {
+	if rw.err != nil {
+		return
+	}
+	n, err := rw.w.Write(b)
+	rw.n += int64(n)
+	rw.err = err
+}
+
+// writeString writes s out unless a previous write failed.
+func (rw *resWriter) writeString(s string) {
+	if rw.err != nil {
+		return
+	}
+	n, err := io.WriteString(rw.w, s)
+	rw.n += int64(n)
+	rw.err = err
 }
 
 type resFiles []resFile
 
-func (rf resFiles) Len() int           { return len(rf) }
-func (rf resFiles) Less(i, j int) bool { return qt_hash(rf[i].name) < qt_hash(rf[j].name) }
-func (rf resFiles) Swap(i, j int)      { rf[i], rf[j] = rf[j], rf[i] }
+// Qt looks up resources by binary searching on the name hash, so entries
+// must be sorted by hash. Ties are broken by name so that packing the same
+// resources always yields the same data, independently of insertion order.
+func (rf resFiles) Len() int { return len(rf) }
+func (rf resFiles) Less(i, j int) bool {
+	hi, hj := qt_hash(rf[i].name), qt_hash(rf[j].name)
+	return hi < hj || hi == hj && rf[i].name < rf[j].name
+}
+func (rf resFiles) Swap(i, j int) { rf[i], rf[j] = rf[j], rf[i] }
 
 // qt_hash returns the hash of p as determined by the internal qt_hash function in Qt.
 //
@@ -33,6 +33,7 @@ func ResetStats() {
 	// These are absolute values:
 	stats.EnginesAlive = old.EnginesAlive
 	stats.ValuesAlive = old.ValuesAlive
+	stats.ContextsAlive = old.ContextsAlive
 	statsMutex.Unlock()
 	return
 }
@@ -41,6 +42,8 @@ type Statistics struct {
 	EnginesAlive     int
 	ValuesAlive      int
 	ConnectionsAlive int
+	ContextsAlive    int
+	SignalsDropped   int
 }
 
 func (stats *Statistics) enginesAlive(delta int) {
@@ -66,3 +69,19 @@ func (stats *Statistics) connectionsAlive(delta int) {
 		statsMutex.Unlock()
 	}
 }
+
+func (stats *Statistics) contextsAlive(delta int) {
+	if stats != nil {
+		statsMutex.Lock()
+		stats.ContextsAlive += delta
+		statsMutex.Unlock()
+	}
+}
+
+func (stats *Statistics) signalsDropped(delta int) {
+	if stats != nil {
+		statsMutex.Lock()
+		stats.SignalsDropped += delta
+		statsMutex.Unlock()
+	}
+}
@@ -0,0 +1,92 @@
+package qml
+
+// #include <stdlib.h>
+//
+// #include "capi.h"
+//
+import "C"
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"unsafe"
+)
+
+// Color holds a QML color value. Properties of type color are obtained
+// from QML as a Color, and a Color may be set into them. Strings such as
+// "red" or "#ff0000" may also be set into color properties, in which case
+// they are interpreted by QML itself. See ParseColor and FormatColor for
+// converting between colors and their names in Go.
+type Color = color.RGBA
+
+// Point holds a QML point value, as used for instance by the
+// mapToItem method of items.
+type Point struct {
+	X, Y float64
+}
+
+// Size holds a QML size value, as used for instance by the
+// sourceSize property of images.
+type Size struct {
+	Width, Height float64
+}
+
+// Rect holds a QML rect value, as used for instance by the
+// childrenRect property of items.
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// ParseColor returns the color with the provided name, which may be
+// one of the SVG color names known to QML, such as "red" or
+// "transparent", or a hexadecimal form such as "#rgb", "#rrggbb",
+// or "#rrggbbaa".
+//
+// Note that the "#rrggbbaa" form accepted here has the alpha channel
+// last, as produced by FormatColor, while strings with eight hex digits
+// assigned to color properties in QML are interpreted as "#aarrggbb".
+func ParseColor(name string) (Color, error) {
+	if len(name) == 9 && name[0] == '#' {
+		v, err := strconv.ParseUint(name[1:], 16, 32)
+		if err == nil {
+			return Color{R: byte(v >> 24), G: byte(v >> 16), B: byte(v >> 8), A: byte(v)}, nil
+		}
+	} else {
+		cname := C.CString(name)
+		defer C.free(unsafe.Pointer(cname))
+		var rgba C.uint
+		if C.colorFromName(cname, &rgba) != 0 {
+			return Color{R: byte(rgba >> 16), G: byte(rgba >> 8), B: byte(rgba), A: byte(rgba >> 24)}, nil
+		}
+	}
+	return Color{}, fmt.Errorf("invalid color name: %q", name)
+}
+
+// FormatColor returns c in the "#rrggbb" form if it is opaque,
+// or in the "#rrggbbaa" form otherwise. The result may be parsed
+// back into the same color by ParseColor.
+func FormatColor(c Color) string {
+	s := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf("%02x", c.A)
+	}
+	return s
+}
+
+// newCoords returns the provided coordinates in C memory, for shipping
+// geometry values to C++, which releases them.
+func newCoords(coords ...float64) unsafe.Pointer {
+	p := C.malloc(C.size_t(len(coords)) * C.size_t(unsafe.Sizeof(coords[0])))
+	copy((*[4]float64)(p)[:len(coords)], coords)
+	return p
+}
+
+// takeCoords returns the n coordinates at p, shipped by C++,
+// and releases p.
+func takeCoords(p unsafe.Pointer, n int) []float64 {
+	coords := make([]float64, n)
+	copy(coords, (*[4]float64)(p)[:n])
+	C.free(p)
+	return coords
+}